@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"cooder-assist/pkg/agent"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// Exit codes of the process.
+const (
+	exitOK       = 0 // the run completed
+	exitError    = 1 // configuration, provider or model error
+	exitUsage    = 2 // invalid flags or empty prompt
+	exitMaxTurns = 3 // the model was still calling tools at --max-turns
+
+	exitInterrupted = 130 // interrupted with Ctrl-C
+)
+
+// oneShotOutput is the document printed by --output json.
+type oneShotOutput struct {
+	*agent.Result
+	Session string `json:"session,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// runOneShot runs the --prompt flag to completion, prints the answer in the
+// requested format and returns the process exit code.
+func runOneShot(ctx context.Context, a *agent.Agent, chat agent.ChatSession) int {
+	text, err := readPrompt(prompt, os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read prompt: %v\n", err)
+		return exitUsage
+	}
+	if strings.TrimSpace(text) == "" {
+		fmt.Fprintln(os.Stderr, "Empty prompt is not accepted")
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	result, err := a.RunOnce(ctx, chat, text, maxTurns)
+	code := exitOK
+	switch {
+	case ctx.Err() != nil:
+		code = exitInterrupted
+	case errors.Is(err, agent.ErrMaxTurns):
+		code = exitMaxTurns
+	case err != nil:
+		code = exitError
+	}
+
+	if output == outputJSON {
+		doc := oneShotOutput{Result: result}
+		if a.Session != nil {
+			doc.Session = a.Session.ID
+		}
+		if err != nil {
+			doc.Error = err.Error()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(doc); encErr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode output: %v\n", encErr)
+			return exitError
+		}
+		return code
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	if result != nil && result.Text != "" {
+		fmt.Println(strings.TrimRight(result.Text, "\n"))
+	}
+	return code
+}
+
+// readPrompt returns the prompt flag value, reading it from stdin when it is "-".
+func readPrompt(value string, stdin io.Reader) (string, error) {
+	if value != "-" {
+		return value, nil
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
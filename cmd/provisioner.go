@@ -5,86 +5,219 @@ import (
 	"cooder-assist/pkg/agent"
 	"cooder-assist/pkg/config"
 	"cooder-assist/pkg/log"
+	"cooder-assist/pkg/lsp"
 	"cooder-assist/pkg/scanner"
+	"cooder-assist/pkg/session"
 	"cooder-assist/pkg/tools"
 
 	"fmt"
 	"os"
-	"os/signal"
 
 	"github.com/spf13/cobra"
-	"google.golang.org/genai"
 )
 
 var (
-	cfgPath string
-	cfgFile string
-	rootCmd = &cobra.Command{
-		Use:     "codingAssist [flags] [command]",
-		Short:   "coding assist client",
-		Example: `codingAssist --cfgPath=../`,
-		Long:    ``,
+	cfgPath   string
+	cfgFile   string
+	resume    bool
+	sessionID string
+	prompt    string
+	output    string
+	maxTurns  int
+	yes       bool
+	worktree  bool
+	rootCmd   = &cobra.Command{
+		Use:   "codingAssist [flags] [command]",
+		Short: "coding assist client",
+		Example: `codingAssist --cfgPath=../
+codingAssist -p "explain pkg/tools/diff.go" --output json
+git diff | codingAssist -p -`,
+		Long: ``,
 		Run: func(cmd *cobra.Command, args []string) {
-			newProvisioner()
+			newProvisioner(cmd.Flags().Changed("prompt"))
 		},
 	}
 )
 
-func newProvisioner() {
+// newProvisioner sets up the model provider and tools and starts the agent,
+// either in the interactive loop or, when oneShot is set, for the single
+// prompt given with --prompt.
+func newProvisioner(oneShot bool) {
 
-	errExit := false
+	exitCode := exitOK
 	defer func() {
-		if errExit {
-			os.Exit(1)
-			return
-		}
-		os.Exit(0)
+		os.Exit(exitCode)
 	}()
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer stop()
+	if oneShot && output != outputText && output != outputJSON {
+		fmt.Fprintf(os.Stderr, "Invalid --output '%s', expected '%s' or '%s'\n", output, outputText, outputJSON)
+		exitCode = exitUsage
+		return
+	}
+
+	// Interrupts are handled by the agent loop, which cancels the in-flight
+	// request on Ctrl-C and only exits when idle at the prompt.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	cfg, err := config.InitConfig(cfgFile, cfgPath)
 	if err != nil {
-		fmt.Println("Invalid configuration")
-		errExit = true
+		fmt.Fprintln(os.Stderr, "Invalid configuration")
+		exitCode = exitError
 		return
 	}
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		Backend: genai.BackendGeminiAPI,
-	})
 	logger := log.Init("provisioner", "./logdump.log")
 	scanner := scanner.New()
+	sessions, err := session.NewStore(cfg.Workspace.Root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open session store: %v\n", err)
+		exitCode = exitError
+		return
+	}
+	sess, err := openSession(sessions, cfg.ModelConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load session: %v\n", err)
+		exitCode = exitError
+		return
+	}
+	wt, root, err := openWorktree(ctx, worktree || cfg.Workspace.Worktree, sessions, sess, cfg.Workspace.Root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open worktree: %v\n", err)
+		exitCode = exitError
+		return
+	}
+	workspace, err := tools.NewWorkspace(root, cfg.Workspace.ReadOnlyRoots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid workspace: %v\n", err)
+		exitCode = exitError
+		return
+	}
+	checkpoints := tools.OpenCheckpoints(sessions.CheckpointDir(sess.ID))
+	languageServer := newLanguageServer(cfg.LSP, workspace.Root)
+	if languageServer != nil {
+		defer languageServer.Close()
+	}
 	tools := tools.New()
-	agent := agent.New(cfg.ModelConfig.Model, logger, client, scanner, tools)
+	tools.Approver = approver(oneShot, scanner)
+	tools.Workspace = workspace
+	tools.Commands = commandPolicy(cfg.Commands)
+	tools.MaxReadBytes = cfg.Workspace.MaxReadBytes
+	tools.Checkpoints = checkpoints
+	tools.LSP = languageServer
+	tools.AppendDiagnostics = cfg.LSP.AppendDiagnostics
 
-	systemInstr := &genai.Content{
-		Parts: []*genai.Part{
-			{Text: "Answer concisely. Ask clarifying questions, if necessary."},
-		},
-		Role: "user",
+	systemInstr := "Answer concisely. Ask clarifying questions, if necessary."
+	provider, err := agent.NewProvider(ctx, cfg.ModelConfig, systemInstr, tools)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialise model provider: %v\n", err)
+		exitCode = exitError
+		return
+	}
+	agent := agent.New(cfg.ModelConfig.Model, logger, provider, scanner, tools)
+	agent.Sessions = sessions
+	agent.Session = sess
+
+	chat, err := provider.NewSession(ctx, sess.History)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start chat session: %v\n", err)
+		exitCode = exitError
+		return
 	}
-	config := &genai.GenerateContentConfig{
-		SystemInstruction: systemInstr,
-		CandidateCount:    1,
-		Tools:             tools,
+
+	if oneShot {
+		exitCode = runOneShot(ctx, agent, chat)
+		if wt != nil {
+			fmt.Fprintf(os.Stderr, "The changes are on branch %s in %s\n", wt.Branch, wt.Dir)
+		}
+		return
 	}
-	chat, err := client.Chats.Create(ctx, cfg.ModelConfig.Model, config, nil)
 
+	fmt.Println("Cooder Assist")
 	agent.Run(ctx, chat)
+	if wt != nil {
+		finishWorktree(ctx, wt, scanner)
+	}
+
+}
 
+// approver returns how mutating tool calls are approved. Interactive runs ask
+// in the terminal; one-shot runs approve everything with --yes and reject
+// everything otherwise, since nobody is there to answer.
+func approver(oneShot bool, scn scanner.Scanner) tools.Approver {
+	switch {
+	case yes:
+		return tools.ApproveAll
+	case oneShot:
+		return tools.RejectAll("changes cannot be approved in a non-interactive run; ask the user to re-run with --yes")
+	default:
+		return agent.TerminalApprover{Scanner: scn}
+	}
+}
+
+// commandPolicy converts the commands configuration for run_command.
+func commandPolicy(cfg config.CommandsConfig) tools.CommandPolicy {
+	return tools.CommandPolicy{
+		Allow:          cfg.Allow,
+		Deny:           cfg.Deny,
+		Timeout:        cfg.Timeout,
+		MaxOutputBytes: cfg.MaxOutputBytes,
+		Env:            cfg.Env,
+		NoNetwork:      cfg.NoNetwork,
+	}
+}
+
+// newLanguageServer returns the client of the configured language server, or
+// nil when it is disabled. The server itself is started on first use.
+func newLanguageServer(cfg config.LSPConfig, root string) *lsp.Client {
+	if cfg.Disabled {
+		return nil
+	}
+	return lsp.New(root, lsp.Config{
+		Command:    cfg.Command,
+		Args:       cfg.Args,
+		Extensions: cfg.Extensions,
+		LanguageID: cfg.LanguageID,
+		Timeout:    cfg.Timeout,
+	})
+}
+
+// openSession returns the session selected by the --resume and --session
+// flags, or a new one when neither is set.
+func openSession(store *session.Store, cfg config.ModelConfig) (*session.Session, error) {
+	var sess *session.Session
+	var err error
+	switch {
+	case sessionID != "":
+		sess, err = store.Load(sessionID)
+	case resume:
+		sess, err = store.Latest()
+	default:
+		return store.New(cfg.Provider, cfg.Model), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Resumed session %s (%d messages)\n", sess.ID, len(sess.History))
+	return sess, nil
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgPath, "cfgPath", "", "config file path (default is '.')")
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "cfgFile", "", "config file name (default is 'agent-config-default.yml')")
+	rootCmd.Flags().BoolVar(&resume, "resume", false, "resume the most recent session of this workspace")
+	rootCmd.Flags().StringVar(&sessionID, "session", "", "resume the session with the given id")
+	rootCmd.Flags().StringVarP(&prompt, "prompt", "p", "", "run a single prompt non-interactively and exit ('-' reads the prompt from stdin)")
+	rootCmd.Flags().StringVar(&output, "output", outputText, "output format of --prompt: 'text' or 'json'")
+	rootCmd.Flags().BoolVarP(&yes, "yes", "y", false, "approve file edits, commands and commits without asking")
+	rootCmd.Flags().BoolVar(&worktree, "worktree", false, "work in a new git worktree and branch for the session, merged, kept or discarded on exit")
+	rootCmd.Flags().IntVar(&maxTurns, "max-turns", agent.DefaultMaxTurns, "maximum number of model requests for --prompt")
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(sessionsCmd)
 }
 
 func ExecuteRootCommand() {
-	fmt.Println("Cooder Assist")
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println("Error executing root command")
+		fmt.Fprintln(os.Stderr, "Error executing root command")
 		os.Exit(1)
 	}
 }
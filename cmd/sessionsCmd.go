@@ -0,0 +1,138 @@
+package main
+
+import (
+	"cooder-assist/pkg/config"
+	"cooder-assist/pkg/session"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/genai"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage saved conversation sessions",
+	Long: `sessions lists, shows and deletes the conversations saved for the current
+workspace. A saved session can be resumed with --resume or --session <id>.`,
+	Example: "codingAssist sessions list",
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved sessions, most recent first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openSessionStore()
+		if err != nil {
+			return err
+		}
+		sessions, skipped, err := store.List()
+		if err != nil {
+			return err
+		}
+		for _, err := range skipped {
+			fmt.Fprintf(os.Stderr, "Skipped unreadable session: %v\n", err)
+		}
+		if len(sessions) == 0 {
+			fmt.Println("No saved sessions")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUPDATED\tMODEL\tMESSAGES\tTITLE")
+		for _, sess := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", sess.ID, sess.UpdatedAt.Format("2006-01-02 15:04"), sess.Model, len(sess.History), sess.Title())
+		}
+		return w.Flush()
+	},
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print the conversation of a saved session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openSessionStore()
+		if err != nil {
+			return err
+		}
+		sess, err := store.Load(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Session %s (%s %s), updated %s\n\n", sess.ID, sess.Provider, sess.Model, sess.UpdatedAt.Format("2006-01-02 15:04"))
+		for _, content := range sess.History {
+			printContent(content)
+		}
+		return nil
+	},
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:   "delete <id>...",
+	Short: "Delete saved sessions",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openSessionStore()
+		if err != nil {
+			return err
+		}
+		for _, id := range args {
+			if err := store.Delete(id); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted session %s\n", id)
+		}
+		return nil
+	},
+}
+
+// openSessionStore returns the session store of the configured workspace
+// root.
+func openSessionStore() (*session.Store, error) {
+	cfg, err := config.InitConfig(cfgFile, cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	return session.NewStore(cfg.Workspace.Root)
+}
+
+// printContent prints one turn of a saved conversation.
+func printContent(content *genai.Content) {
+	for _, part := range content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			args, _ := json.Marshal(part.FunctionCall.Args)
+			fmt.Printf("\033[1;94m[Tool Call]\033[0m %s %s\n", part.FunctionCall.Name, args)
+		case part.FunctionResponse != nil:
+			resp, _ := json.Marshal(part.FunctionResponse.Response)
+			fmt.Printf("\033[1;94m[Tool Output]\033[0m %s %s\n", part.FunctionResponse.Name, truncate(string(resp), 200))
+		case part.Text != "":
+			label := "\u001b[94mYou\u001b[0m"
+			if content.Role == genai.RoleModel {
+				label = "\u001b[93mModel\u001b[0m"
+			}
+			fmt.Printf("%s: %s\n", label, strings.TrimRight(part.Text, "\n"))
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsDeleteCmd)
+}
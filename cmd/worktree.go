@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"cooder-assist/pkg/scanner"
+	"cooder-assist/pkg/session"
+	"cooder-assist/pkg/tools"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// openWorktree returns the worktree of the session when worktree mode is on
+// or the session already has one, and nil otherwise. root is the configured
+// workspace root; the returned root is the same directory inside the
+// worktree.
+func openWorktree(ctx context.Context, enabled bool, store *session.Store, sess *session.Session, root string) (*tools.Worktree, string, error) {
+	dir := store.WorktreeDir(sess.ID)
+	if _, err := os.Stat(dir); !enabled && err != nil {
+		return nil, root, nil
+	}
+	if root == "" {
+		root = "."
+	}
+	wt, err := tools.OpenWorktree(ctx, root, dir, tools.WorktreeBranchPrefix+sess.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Keep a workspace rooted below the top of the repository at the same
+	// place in the worktree.
+	abs, err := filepath.Abs(root)
+	if err == nil {
+		abs, err = filepath.EvalSymlinks(abs)
+	}
+	rel, relErr := filepath.Rel(wt.Repo, abs)
+	if err != nil || relErr != nil || strings.HasPrefix(rel, "..") {
+		rel = "."
+	}
+	fmt.Fprintf(os.Stderr, "Working in worktree %s on branch %s\n", wt.Dir, wt.Branch)
+	return wt, filepath.Join(wt.Dir, rel), nil
+}
+
+// finishWorktree asks the user what to do with the session worktree once the
+// interactive loop has ended.
+func finishWorktree(ctx context.Context, wt *tools.Worktree, scn scanner.Scanner) {
+	for {
+		fmt.Printf("The changes are on branch %s in %s.\n[m]erge into %s / [k]eep for later / [d]iscard: ", wt.Branch, wt.Dir, wt.Base)
+		answer, ok := scn.GetLine(ctx)
+		if !ok {
+			answer = "k"
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "m", "merge":
+			out, err := wt.Merge(ctx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Merge failed: %v\n", err)
+				continue
+			}
+			fmt.Print(out)
+			fmt.Printf("Merged %s into %s and removed the worktree\n", wt.Branch, wt.Base)
+			return
+		case "k", "keep":
+			fmt.Println("Kept the worktree; resume the session to continue working in it")
+			return
+		case "d", "discard":
+			if err := wt.Discard(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "Discard failed: %v\n", err)
+				continue
+			}
+			fmt.Printf("Discarded the worktree and branch %s\n", wt.Branch)
+			return
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"context"
+	"cooder-assist/pkg/log"
+	"cooder-assist/pkg/scanner"
+	"cooder-assist/pkg/session"
+	"cooder-assist/pkg/tools"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"google.golang.org/genai"
+)
+
+type Agent struct {
+	Provider Provider
+	Model    string
+	Logger   log.Logger
+	Scanner  scanner.Scanner
+	Tools    *tools.Tools
+	// Sessions and Session, when set, persist the history after every turn.
+	Sessions *session.Store
+	Session  *session.Session
+}
+
+type AgentState int
+
+const (
+	StateUndefined AgentState = iota
+	UserInput
+	RunInference
+	Process
+	Error
+)
+
+func New(model string, l log.Logger, provider Provider, scanner scanner.Scanner, tools *tools.Tools) *Agent {
+
+	return &Agent{
+		Provider: provider,
+		Model:    model,
+		Logger:   l,
+		Scanner:  scanner,
+		Tools:    tools,
+	}
+}
+func colorizeDiff(diff string) string {
+	return tools.ColorizeDiff(diff)
+}
+
+/*
+Run function manages the interaction loop between the user and the model. It takes a context and a ChatSession created by the agent's Provider as input.
+
+It orchestrates the conversation between the user and the model, handling user input, sending messages to the provider, processing the model's responses (including tool calls), and displaying the results to the user.
+*/
+func (a *Agent) Run(ctx context.Context, chat ChatSession) error {
+	// Ctrl-C at the prompt exits, while Ctrl-C during a turn only cancels the
+	// in-flight request and returns to the prompt.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	var conversation []genai.Part
+	readUserInput := true
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Goodbye!")
+			return nil
+		default:
+		}
+		if readUserInput {
+			fmt.Print("\u001b[94mYou\u001b[0m:")
+			userInput, ok, interrupted := a.readUserMessage(ctx, interrupts)
+			if interrupted {
+				fmt.Println("\nGoodbye!")
+				return nil
+			}
+			if !ok {
+				fmt.Println("Empty user input is not accepted")
+				continue
+			}
+			if handled, note := a.runCommand(userInput); handled {
+				// The note is sent along with the next message.
+				if note != "" {
+					conversation = append(conversation, genai.Part{Text: note})
+				}
+				continue
+			}
+			conversation = append(conversation, genai.Part{Text: userInput})
+			readUserInput = false // Only set to false after successful input
+		}
+
+		if len(conversation) != 0 {
+			turnCtx, stopTurn := interruptible(ctx, interrupts)
+			conversation, readUserInput = a.runTurn(turnCtx, chat, conversation)
+			stopTurn()
+			a.saveSession(chat)
+		}
+	}
+}
+
+// runTurn sends the conversation, prints the reply and executes the requested
+// tools. It returns the function responses to send next and whether the user
+// should be asked for input instead.
+func (a *Agent) runTurn(ctx context.Context, chat ChatSession, conversation []genai.Part) ([]genai.Part, bool) {
+	response, err := a.streamResponse(ctx, chat, conversation)
+	if ctx.Err() != nil {
+		fmt.Println("\n\033[1;91m[Interrupted]\033[0m")
+		// Keep unanswered function responses so the next turn still answers
+		// the model's function calls.
+		return functionResponses(conversation), true
+	}
+	if err != nil {
+		fmt.Printf("Error sending message: %v. Try again\n", err)
+		return conversation, true // Reset to allow user input again
+	}
+
+	a.Logger.Info("Model usage", "input_tokens", response.Usage.InputTokens, "output_tokens", response.Usage.OutputTokens)
+
+	if len(response.Parts) == 0 {
+		fmt.Println("I need more context")
+		return conversation, true
+	}
+
+	var next []genai.Part
+	for _, call := range response.FunctionCalls() {
+		a.Logger.Info("Executing tool", "tool_name", call.Name, "prompt", call.Args)
+		resp := a.Tools.ExecuteTool(ctx, call)
+
+		if output, ok := resp.Response["output"].(string); ok {
+			fmt.Printf("\n\033[1;94m[Tool Output]\033[0m\n%s\n", colorizeDiff(output))
+		} else if errMsg, ok := resp.Response["error"].(string); ok {
+			fmt.Printf("\n\033[1;91m[Tool Error]\033[0m %s\n", errMsg)
+		}
+		next = append(next, genai.Part{FunctionResponse: resp})
+	}
+
+	if len(next) > 0 && ctx.Err() != nil {
+		fmt.Println("\n\033[1;91m[Interrupted]\033[0m")
+		return next, true
+	}
+	return next, len(next) == 0
+}
+
+// saveSession persists the chat history to the agent's session, if any.
+func (a *Agent) saveSession(chat ChatSession) {
+	if a.Sessions == nil || a.Session == nil {
+		return
+	}
+	a.Session.History = chat.History()
+	if err := a.Sessions.Save(a.Session); err != nil {
+		a.Logger.Error("Failed to save session", "session", a.Session.ID, "error", err)
+	}
+}
+
+// streamResponse sends the conversation and prints text as it streams in.
+// The returned response holds every part received, with function calls to be
+// dispatched once the stream has completed.
+func (a *Agent) streamResponse(ctx context.Context, chat ChatSession, conversation []genai.Part) (*Response, error) {
+	response := &Response{}
+	printing := false
+	defer func() {
+		if printing {
+			fmt.Println()
+		}
+	}()
+
+	for chunk, err := range chat.SendMessageStream(ctx, conversation...) {
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range chunk.Parts {
+			if len(part.Text) != 0 {
+				if !printing {
+					fmt.Print("\u001b[93mGemini\u001b[0m: ")
+					printing = true
+				}
+				fmt.Print(part.Text)
+			}
+		}
+		response.Parts = append(response.Parts, chunk.Parts...)
+		if chunk.Usage != (Usage{}) {
+			response.Usage = chunk.Usage
+		}
+	}
+	return response, ctx.Err()
+}
+
+// readUserMessage waits for the next user message. interrupted is true when
+// Ctrl-C was pressed or ctx was cancelled while waiting; the read is then
+// abandoned, so later prompts get the next line typed.
+func (a *Agent) readUserMessage(ctx context.Context, interrupts <-chan os.Signal) (message string, ok bool, interrupted bool) {
+	ctx, stop := interruptible(ctx, interrupts)
+	defer stop()
+	message, ok = a.Scanner.GetUserMessage(ctx)
+	return message, ok, ctx.Err() != nil
+}
+
+// interruptible derives a context that is cancelled when an interrupt arrives.
+// The returned stop function must be called once the work is done so later
+// interrupts are delivered to the caller again.
+func interruptible(ctx context.Context, interrupts <-chan os.Signal) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-interrupts:
+			cancel()
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		<-stopped
+		cancel()
+	}
+}
+
+// functionResponses returns the function response parts of a conversation.
+func functionResponses(conversation []genai.Part) []genai.Part {
+	var responses []genai.Part
+	for _, part := range conversation {
+		if part.FunctionResponse != nil {
+			responses = append(responses, part)
+		}
+	}
+	return responses
+}
@@ -0,0 +1,374 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"cooder-assist/pkg/tools"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const (
+	defaultAnthropicBaseURL   = "https://api.anthropic.com/v1"
+	defaultAnthropicAPIKeyEnv = "ANTHROPIC_API_KEY"
+	anthropicVersion          = "2023-06-01"
+	anthropicMaxTokens        = 8192
+)
+
+// AnthropicProvider talks to the Anthropic Messages API. Tool calls are
+// exchanged as tool_use and tool_result content blocks.
+type AnthropicProvider struct {
+	// BaseURL is the API root including the version, e.g. https://api.anthropic.com/v1.
+	BaseURL           string
+	APIKey            string
+	Model             string
+	MaxTokens         int
+	SystemInstruction string
+	Tools             []anthropicTool
+	HTTPClient        *http.Client
+}
+
+// NewAnthropicProvider creates a provider for the Anthropic Messages API. The
+// API key is read from the apiKeyEnv environment variable.
+func NewAnthropicProvider(baseURL, apiKeyEnv, model, systemInstruction string, t *tools.Tools) *AnthropicProvider {
+	if baseURL == "" {
+		baseURL = defaultAnthropicBaseURL
+	}
+	if apiKeyEnv == "" {
+		apiKeyEnv = defaultAnthropicAPIKeyEnv
+	}
+
+	var defs []anthropicTool
+	for _, decl := range functionDeclarations(t) {
+		defs = append(defs, anthropicTool{
+			Name:        decl.Name,
+			Description: decl.Description,
+			InputSchema: jsonSchema(decl.Parameters),
+		})
+	}
+
+	return &AnthropicProvider{
+		BaseURL:           strings.TrimSuffix(baseURL, "/"),
+		APIKey:            os.Getenv(apiKeyEnv),
+		Model:             model,
+		MaxTokens:         anthropicMaxTokens,
+		SystemInstruction: systemInstruction,
+		Tools:             defs,
+		HTTPClient:        http.DefaultClient,
+	}
+}
+
+func (p *AnthropicProvider) NewSession(ctx context.Context, history []*genai.Content) (ChatSession, error) {
+	return &anthropicSession{provider: p, history: history}, nil
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is a content block of type text, tool_use or tool_result.
+type anthropicBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Input any    `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// anthropicStreamEvent covers the fields used from every streamed event type.
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock anthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type anthropicSession struct {
+	provider *AnthropicProvider
+	history  []*genai.Content
+}
+
+func (s *anthropicSession) History() []*genai.Content {
+	return s.history
+}
+
+func (s *anthropicSession) SendMessage(ctx context.Context, parts ...genai.Part) (*Response, error) {
+	input := userContent(parts)
+	var result anthropicResponse
+	if err := s.provider.post(ctx, "/messages", s.request(input), &result); err != nil {
+		return nil, err
+	}
+
+	output := anthropicOutput(result.Content)
+	s.history = append(s.history, input, output)
+	return &Response{Parts: output.Parts, Usage: result.Usage.toUsage()}, nil
+}
+
+func (s *anthropicSession) SendMessageStream(ctx context.Context, parts ...genai.Part) iter.Seq2[*Response, error] {
+	return func(yield func(*Response, error) bool) {
+		input := userContent(parts)
+		req := s.request(input)
+		req.Stream = true
+
+		resp, err := s.provider.do(ctx, "/messages", req)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		// Text deltas are yielded as they arrive while tool_use input is
+		// accumulated as partial JSON and decoded when the block stops.
+		var blocks []anthropicBlock
+		var inputJSON []string
+		var usage anthropicUsage
+		stopped := false
+		err = readSSE(resp.Body, func(_, data string) error {
+			var event anthropicStreamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				return fmt.Errorf("anthropic: failed to decode stream event: %w", err)
+			}
+			switch event.Type {
+			case "message_start":
+				usage.InputTokens = event.Message.Usage.InputTokens
+			case "content_block_start":
+				for len(blocks) <= event.Index {
+					blocks = append(blocks, anthropicBlock{})
+					inputJSON = append(inputJSON, "")
+				}
+				blocks[event.Index] = event.ContentBlock
+			case "content_block_delta":
+				if event.Index >= len(blocks) {
+					return fmt.Errorf("anthropic: delta for unknown content block %d", event.Index)
+				}
+				switch event.Delta.Type {
+				case "text_delta":
+					blocks[event.Index].Text += event.Delta.Text
+					if !yield(&Response{Parts: []*genai.Part{{Text: event.Delta.Text}}}, nil) {
+						stopped = true
+						return io.EOF
+					}
+				case "input_json_delta":
+					inputJSON[event.Index] += event.Delta.PartialJSON
+				}
+			case "content_block_stop":
+				if event.Index < len(blocks) && blocks[event.Index].Type == "tool_use" && inputJSON[event.Index] != "" {
+					var args map[string]any
+					if err := json.Unmarshal([]byte(inputJSON[event.Index]), &args); err != nil {
+						return fmt.Errorf("anthropic: invalid input for tool_use '%s': %w", blocks[event.Index].Name, err)
+					}
+					blocks[event.Index].Input = args
+				}
+			case "message_delta":
+				usage.OutputTokens = event.Usage.OutputTokens
+			case "message_stop":
+				return io.EOF
+			case "error":
+				return fmt.Errorf("anthropic: %s: %s", event.Error.Type, event.Error.Message)
+			}
+			return nil
+		})
+		if stopped {
+			return
+		}
+		if err != nil && err != io.EOF {
+			yield(nil, fmt.Errorf("anthropic: stream failed: %w", err))
+			return
+		}
+
+		output := anthropicOutput(blocks)
+		s.history = append(s.history, input, output)
+		yield(&Response{Parts: functionCallParts(output.Parts), Usage: usage.toUsage()}, nil)
+	}
+}
+
+func (s *anthropicSession) request(input *genai.Content) anthropicRequest {
+	return anthropicRequest{
+		Model:     s.provider.Model,
+		MaxTokens: s.provider.MaxTokens,
+		System:    s.provider.SystemInstruction,
+		Messages:  anthropicMessages(append(s.history, input)),
+		Tools:     s.provider.Tools,
+	}
+}
+
+// anthropicOutput converts the content blocks of a reply into a model turn.
+func anthropicOutput(blocks []anthropicBlock) *genai.Content {
+	output := &genai.Content{Role: genai.RoleModel}
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				output.Parts = append(output.Parts, &genai.Part{Text: block.Text})
+			}
+		case "tool_use":
+			args, _ := block.Input.(map[string]any)
+			output.Parts = append(output.Parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+				ID:   block.ID,
+				Name: block.Name,
+				Args: args,
+			}})
+		}
+	}
+	return output
+}
+
+func (u anthropicUsage) toUsage() Usage {
+	return Usage{
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+		TotalTokens:  u.InputTokens + u.OutputTokens,
+	}
+}
+
+// anthropicMessages converts the genai history into Messages API turns.
+// Function calls become tool_use blocks and function responses become
+// tool_result blocks linked to them by ID.
+func anthropicMessages(history []*genai.Content) []anthropicMessage {
+	var messages []anthropicMessage
+	for _, content := range history {
+		msg := anthropicMessage{Role: "user"}
+		if content.Role == genai.RoleModel {
+			msg.Role = "assistant"
+		}
+		calls, responses := 0, 0
+
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				input := part.FunctionCall.Args
+				if input == nil {
+					input = map[string]any{}
+				}
+				msg.Content = append(msg.Content, anthropicBlock{
+					Type:  "tool_use",
+					ID:    toolCallID(part.FunctionCall.ID, part.FunctionCall.Name, calls),
+					Name:  part.FunctionCall.Name,
+					Input: input,
+				})
+				calls++
+			case part.FunctionResponse != nil:
+				body, _ := json.Marshal(part.FunctionResponse.Response)
+				_, isError := part.FunctionResponse.Response["error"]
+				msg.Content = append(msg.Content, anthropicBlock{
+					Type:      "tool_result",
+					ToolUseID: toolCallID(part.FunctionResponse.ID, part.FunctionResponse.Name, responses),
+					Content:   string(body),
+					IsError:   isError,
+				})
+				responses++
+			case part.Text != "":
+				msg.Content = append(msg.Content, anthropicBlock{Type: "text", Text: part.Text})
+			}
+		}
+
+		if len(msg.Content) == 0 {
+			continue
+		}
+		// The API requires alternating roles, so merge consecutive turns.
+		if n := len(messages); n > 0 && messages[n-1].Role == msg.Role {
+			messages[n-1].Content = append(messages[n-1].Content, msg.Content...)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func (p *AnthropicProvider) post(ctx context.Context, path string, body, out any) error {
+	resp, err := p.do(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("anthropic: failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("anthropic: failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// do sends the request and returns the response when the status is 200 OK.
+func (p *AnthropicProvider) do(ctx context.Context, path string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", anthropicVersion)
+	if p.APIKey != "" {
+		req.Header.Set("x-api-key", p.APIKey)
+	}
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("anthropic: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
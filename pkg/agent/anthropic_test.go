@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// anthropicServer serves the replies in order, recording the requests it got.
+func anthropicServer(t *testing.T, contentType string, replies []string) (*AnthropicProvider, func() []anthropicRequest) {
+	var mu sync.Mutex
+	var requests []anthropicRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != anthropicVersion {
+			http.Error(w, "missing headers", http.StatusUnauthorized)
+			return
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(requests) >= len(replies) {
+			http.Error(w, "unexpected request", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(replies[len(requests)]))
+		requests = append(requests, req)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := &AnthropicProvider{BaseURL: srv.URL, APIKey: "test-key", Model: "test-model", MaxTokens: 100, HTTPClient: srv.Client()}
+	return p, func() []anthropicRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+// anthropicEvents joins the payloads into a server-sent event stream, naming
+// each event after its type as the Messages API does.
+func anthropicEvents(payloads ...string) string {
+	var b strings.Builder
+	for _, p := range payloads {
+		var event struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal([]byte(p), &event)
+		fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", event.Type, p)
+	}
+	return b.String()
+}
+
+func TestAnthropicToolUse(t *testing.T) {
+	tests := []struct {
+		name        string
+		stream      bool
+		contentType string
+		replies     []string
+	}{
+		{
+			name:        "plain",
+			contentType: "application/json",
+			replies: []string{
+				`{"content":[
+					{"type":"text","text":"Reading."},
+					{"type":"tool_use","id":"toolu_a","name":"read_file","input":{"path":"a.go"}},
+					{"type":"tool_use","id":"toolu_b","name":"read_file","input":{"path":"b.go"}}
+				],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`,
+				`{"content":[{"type":"text","text":"done"}],"stop_reason":"end_turn","usage":{"input_tokens":20,"output_tokens":1}}`,
+			},
+		},
+		{
+			name:        "streamed",
+			stream:      true,
+			contentType: "text/event-stream",
+			replies: []string{
+				anthropicEvents(
+					`{"type":"message_start","message":{"usage":{"input_tokens":10,"output_tokens":1}}}`,
+					`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+					`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Read"}}`,
+					`{"type":"ping"}`,
+					`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ing."}}`,
+					`{"type":"content_block_stop","index":0}`,
+					`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_a","name":"read_file","input":{}}}`,
+					`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":""}}`,
+					`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\": "}}`,
+					`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"a.go\"}"}}`,
+					`{"type":"content_block_stop","index":1}`,
+					`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_b","name":"read_file","input":{}}}`,
+					`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"path\":\"b.go\"}"}}`,
+					`{"type":"content_block_stop","index":2}`,
+					`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":5}}`,
+					`{"type":"message_stop"}`,
+				),
+				anthropicEvents(
+					`{"type":"message_start","message":{"usage":{"input_tokens":20,"output_tokens":1}}}`,
+					`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+					`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"do"}}`,
+					`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"ne"}}`,
+					`{"type":"content_block_stop","index":0}`,
+					`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":1}}`,
+					`{"type":"message_stop"}`,
+				),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, requests := anthropicServer(t, tt.contentType, tt.replies)
+			chat, err := p.NewSession(context.Background(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			send := func(parts ...genai.Part) *Response {
+				t.Helper()
+				if !tt.stream {
+					resp, err := chat.SendMessage(context.Background(), parts...)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return resp
+				}
+				merged := &Response{}
+				for resp, err := range chat.SendMessageStream(context.Background(), parts...) {
+					if err != nil {
+						t.Fatal(err)
+					}
+					merged.Parts = append(merged.Parts, resp.Parts...)
+					if resp.Usage != (Usage{}) {
+						merged.Usage = resp.Usage
+					}
+				}
+				return merged
+			}
+
+			resp := send(genai.Part{Text: "read both files"})
+			if got := resp.Text(); got != "Reading." {
+				t.Errorf("text = %q, want %q", got, "Reading.")
+			}
+			want := []*genai.FunctionCall{
+				{ID: "toolu_a", Name: "read_file", Args: map[string]any{"path": "a.go"}},
+				{ID: "toolu_b", Name: "read_file", Args: map[string]any{"path": "b.go"}},
+			}
+			if got := resp.FunctionCalls(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("function calls = %+v, want %+v", got, want)
+			}
+			if want := (Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}); resp.Usage != want {
+				t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+			}
+
+			var responses []genai.Part
+			for _, call := range resp.FunctionCalls() {
+				responses = append(responses, genai.Part{FunctionResponse: &genai.FunctionResponse{
+					ID:       call.ID,
+					Name:     call.Name,
+					Response: map[string]any{"output": call.Args["path"]},
+				}})
+			}
+			resp = send(responses...)
+			if got := resp.Text(); got != "done" {
+				t.Errorf("text = %q, want %q", got, "done")
+			}
+
+			reqs := requests()
+			if len(reqs) != 2 {
+				t.Fatalf("got %d requests, want 2", len(reqs))
+			}
+			for _, req := range reqs {
+				if req.Stream != tt.stream {
+					t.Errorf("stream = %v, want %v", req.Stream, tt.stream)
+				}
+			}
+			// The second request replays the text and tool_use blocks and
+			// answers each call with a tool_result linked by ID.
+			msgs := reqs[1].Messages
+			if len(msgs) != 3 {
+				t.Fatalf("got %d messages, want 3: %+v", len(msgs), msgs)
+			}
+			var roles []string
+			for _, msg := range msgs {
+				roles = append(roles, msg.Role)
+			}
+			if want := []string{"user", "assistant", "user"}; !reflect.DeepEqual(roles, want) {
+				t.Errorf("roles = %v, want %v", roles, want)
+			}
+			wantAssistant := []anthropicBlock{
+				{Type: "text", Text: "Reading."},
+				{Type: "tool_use", ID: "toolu_a", Name: "read_file", Input: map[string]any{"path": "a.go"}},
+				{Type: "tool_use", ID: "toolu_b", Name: "read_file", Input: map[string]any{"path": "b.go"}},
+			}
+			if !reflect.DeepEqual(msgs[1].Content, wantAssistant) {
+				t.Errorf("assistant blocks = %+v, want %+v", msgs[1].Content, wantAssistant)
+			}
+			wantResults := []anthropicBlock{
+				{Type: "tool_result", ToolUseID: "toolu_a", Content: `{"output":"a.go"}`},
+				{Type: "tool_result", ToolUseID: "toolu_b", Content: `{"output":"b.go"}`},
+			}
+			if !reflect.DeepEqual(msgs[2].Content, wantResults) {
+				t.Errorf("tool results = %+v, want %+v", msgs[2].Content, wantResults)
+			}
+		})
+	}
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}` + "\n"))
+	}))
+	t.Cleanup(srv.Close)
+	p := &AnthropicProvider{BaseURL: srv.URL, Model: "test-model", MaxTokens: 100, HTTPClient: srv.Client()}
+	chat, err := p.NewSession(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `anthropic: unexpected status 429: {"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`
+
+	if _, err := chat.SendMessage(context.Background(), genai.Part{Text: "hi"}); err == nil || err.Error() != want {
+		t.Errorf("SendMessage error = %v, want %s", err, want)
+	}
+	var streamErr error
+	for _, err := range chat.SendMessageStream(context.Background(), genai.Part{Text: "hi"}) {
+		if err != nil {
+			streamErr = err
+		}
+	}
+	if streamErr == nil || streamErr.Error() != want {
+		t.Errorf("SendMessageStream error = %v, want %s", streamErr, want)
+	}
+	if n := len(chat.History()); n != 0 {
+		t.Errorf("history has %d turns after failed requests, want 0", n)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	p, _ := anthropicServer(t, "text/event-stream", []string{anthropicEvents(
+		`{"type":"message_start","message":{"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	)})
+	chat, err := p.NewSession(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamErr error
+	for _, err := range chat.SendMessageStream(context.Background(), genai.Part{Text: "hi"}) {
+		if err != nil {
+			streamErr = err
+		}
+	}
+	if streamErr == nil || !strings.Contains(streamErr.Error(), "overloaded_error: Overloaded") {
+		t.Errorf("error = %v, want the streamed error event", streamErr)
+	}
+}
@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"context"
+	"cooder-assist/pkg/scanner"
+	"cooder-assist/pkg/tools"
+	"fmt"
+	"strings"
+)
+
+// TerminalApprover shows the pending change in the terminal and asks the user
+// to approve it. Pressing Ctrl-C while it waits rejects the change.
+type TerminalApprover struct {
+	Scanner scanner.Scanner
+}
+
+func (t TerminalApprover) Approve(ctx context.Context, req tools.ApprovalRequest) tools.Approval {
+	fmt.Printf("\n\033[1;93m[Approval Required]\033[0m %s\n", req.Summary)
+	if req.Preview != "" {
+		fmt.Println(colorizeDiff(strings.TrimRight(req.Preview, "\n")))
+	}
+
+	always := "this tool"
+	if req.Scope != "" {
+		always = fmt.Sprintf("'%s'", req.Scope)
+	}
+	for {
+		fmt.Printf("Apply this change? [y]es / [n]o / [a]lways for %s: ", always)
+		answer, ok := t.Scanner.GetLine(ctx)
+		if ctx.Err() != nil {
+			fmt.Println()
+			return tools.Approval{Decision: tools.Reject, Feedback: "the user cancelled the approval"}
+		}
+		if !ok {
+			return tools.Approval{Decision: tools.Reject, Feedback: "no answer from the user"}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return tools.Approval{Decision: tools.Approve}
+		case "a", "always":
+			return tools.Approval{Decision: tools.ApproveAlways}
+		case "n", "no":
+			fmt.Print("Tell the model what to do instead (optional): ")
+			feedback, _ := t.Scanner.GetLine(ctx)
+			return tools.Approval{Decision: tools.Reject, Feedback: strings.TrimSpace(feedback)}
+		}
+	}
+}
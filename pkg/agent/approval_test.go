@@ -0,0 +1,18 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"cooder-assist/pkg/scanner"
+	"cooder-assist/pkg/tools"
+)
+
+func TestTerminalApproverCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	approval := TerminalApprover{Scanner: scanner.New()}.Approve(ctx, tools.ApprovalRequest{Tool: "edit_file", Summary: "Edit a.go"})
+	if approval.Decision != tools.Reject || approval.Feedback != "the user cancelled the approval" {
+		t.Errorf("approval = %+v, want a rejection for the cancelled wait", approval)
+	}
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"cooder-assist/pkg/tools"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const commandsHelp = `Commands:
+  /undo                     revert the files changed by the last tool call
+  /undo N                   revert the last N tool calls
+  /checkpoint list          list the tool calls that can be reverted
+  /checkpoint restore ID    revert checkpoint ID and every later one`
+
+// runCommand handles the interactive commands, which are single lines
+// starting with '/'. It returns false when the input is a message for the
+// model. note, when not empty, tells the model about files the command
+// changed so it does not rely on stale contents.
+func (a *Agent) runCommand(input string) (handled bool, note string) {
+	line := strings.TrimSpace(input)
+	if strings.Contains(line, "\n") {
+		return false, ""
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || (fields[0] != "/undo" && fields[0] != "/checkpoint") {
+		return false, ""
+	}
+
+	checkpoints := a.Tools.Checkpoints
+	if checkpoints == nil {
+		fmt.Println("Checkpoints are not enabled for this session")
+		return true, ""
+	}
+
+	var undone []*tools.Checkpoint
+	var err error
+	switch {
+	case fields[0] == "/undo" && len(fields) == 1:
+		undone, err = checkpoints.Undo(1)
+	case fields[0] == "/undo" && len(fields) == 2:
+		n, convErr := strconv.Atoi(fields[1])
+		if convErr != nil {
+			err = fmt.Errorf("invalid number of changes to undo: '%s'", fields[1])
+			break
+		}
+		undone, err = checkpoints.Undo(n)
+	case len(fields) == 2 && fields[1] == "list", len(fields) == 1:
+		a.listCheckpoints()
+		return true, ""
+	case len(fields) == 3 && fields[1] == "restore":
+		id, convErr := strconv.Atoi(fields[2])
+		if convErr != nil {
+			err = fmt.Errorf("invalid checkpoint id '%s'", fields[2])
+			break
+		}
+		undone, err = checkpoints.Restore(id)
+	default:
+		fmt.Println(commandsHelp)
+		return true, ""
+	}
+	if err != nil {
+		fmt.Printf("\033[1;91m[Error]\033[0m %v\n", err)
+		if len(undone) == 0 {
+			return true, ""
+		}
+	}
+
+	var reverted []string
+	var paths []string
+	for _, cp := range undone {
+		for _, file := range cp.Files {
+			paths = append(paths, file.Path)
+		}
+		reverted = append(reverted, fmt.Sprintf("%s (%s)", cp.Tool, strings.Join(a.checkpointPaths(cp), ", ")))
+	}
+	if a.Tools.LSP != nil {
+		a.Tools.LSP.Sync(paths)
+	}
+	fmt.Printf("Reverted %d change(s):\n  %s\n", len(undone), strings.Join(reverted, "\n  "))
+	return true, fmt.Sprintf("[The user reverted these tool calls, restoring the files to their earlier contents: %s. Read the files again before editing them.]",
+		strings.Join(reverted, "; "))
+}
+
+// listCheckpoints prints the checkpoints of the session, newest first.
+func (a *Agent) listCheckpoints() {
+	list, err := a.Tools.Checkpoints.List()
+	if err != nil {
+		fmt.Printf("\033[1;91m[Error]\033[0m %v\n", err)
+		return
+	}
+	if len(list) == 0 {
+		fmt.Println("No checkpoints in this session")
+		return
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		cp := list[i]
+		fmt.Printf("%4d  %s  %-12s %s\n", cp.ID, cp.Time.Format("15:04:05"), cp.Tool, strings.Join(a.checkpointPaths(cp), ", "))
+	}
+}
+
+// checkpointPaths returns the files of a checkpoint relative to the
+// workspace.
+func (a *Agent) checkpointPaths(cp *tools.Checkpoint) []string {
+	root, _ := filepath.Abs(a.Tools.Workspace.Dir())
+	paths := make([]string, len(cp.Files))
+	for i, file := range cp.Files {
+		paths[i] = file.Path
+		if rel, err := filepath.Rel(root, file.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			paths[i] = rel
+		}
+	}
+	return paths
+}
@@ -2,129 +2,96 @@ package agent
 
 import (
 	"context"
-	"cooder-assist/pkg/log"
-	"cooder-assist/pkg/scanner"
 	"cooder-assist/pkg/tools"
 	"fmt"
-	"strings"
+	"iter"
 
 	"google.golang.org/genai"
 )
 
-type Agent struct {
-	Client  *genai.Client
-	Model   string
-	Logger  log.Logger
-	Scanner scanner.Scanner
-	Tools   tools.Tools
+// GeminiProvider talks to the Gemini API through the genai client.
+type GeminiProvider struct {
+	Client *genai.Client
+	Model  string
+	Config *genai.GenerateContentConfig
 }
 
-type AgentState int
-
-const (
-	StateUndefined AgentState = iota
-	UserInput
-	RunInference
-	Process
-	Error
-)
-
-func New(model string, l log.Logger, client *genai.Client, scanner scanner.Scanner, tools tools.Tools) *Agent {
+// NewGeminiProvider creates a Gemini client using the GOOGLE_API_KEY or
+// GEMINI_API_KEY environment variable.
+func NewGeminiProvider(ctx context.Context, model, systemInstruction string, t *tools.Tools) (*GeminiProvider, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gemini client: %w", err)
+	}
 
-	return &Agent{
-		Client:  client,
-		Model:   model,
-		Logger:  l,
-		Scanner: scanner,
-		Tools:   tools,
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: &genai.Content{
+			Parts: []*genai.Part{{Text: systemInstruction}},
+			Role:  genai.RoleUser,
+		},
+		CandidateCount: 1,
+		Tools:          t.Declarations(),
 	}
+	return &GeminiProvider{Client: client, Model: model, Config: config}, nil
 }
-func colorizeDiff(diff string) string {
-	lines := strings.Split(diff, "\n")
-	for i, line := range lines {
-		if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++") {
-			lines[i] = "\033[32m" + line + "\033[0m" // Green
-		} else if strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---") {
-			lines[i] = "\033[31m" + line + "\033[0m" // Red
-		}
+
+func (p *GeminiProvider) NewSession(ctx context.Context, history []*genai.Content) (ChatSession, error) {
+	chat, err := p.Client.Chats.Create(ctx, p.Model, p.Config, history)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gemini chat: %w", err)
 	}
-	return strings.Join(lines, "\n")
+	return &geminiSession{chat: chat}, nil
 }
 
-/*
-Run function manages the interaction loop between the user and the Gemini model. It takes a context and a Gemini chat object as input.
+type geminiSession struct {
+	chat *genai.Chat
+}
 
-It orchestrates the conversation between the user and the Gemini model, handling user input, sending messages to Gemini, processing Gemini's responses (including tool calls), and displaying the results to the user.
-*/
-/*
-Run function manages the interaction loop between the user and the Gemini model. It takes a context and a Gemini chat object as input.
+func (s *geminiSession) SendMessage(ctx context.Context, parts ...genai.Part) (*Response, error) {
+	result, err := s.chat.SendMessage(ctx, parts...)
+	if err != nil {
+		return nil, err
+	}
 
-It orchestrates the conversation between the user and the Gemini model, handling user input, sending messages to Gemini, processing Gemini's responses (including tool calls), and displaying the results to the user.
-*/
-func (a *Agent) Run(ctx context.Context, chat *genai.Chat) error {
-	var conversation []genai.Part
-	readUserInput := true
-	for {
-		select {
-		case <-ctx.Done():
-			fmt.Println("Goodbye!")
-			return nil
-		default:
-		}
-		if readUserInput {
-			fmt.Print("\u001b[94mYou\u001b[0m:")
-			userInput, ok := a.Scanner.GetUserMessage()
-			if !ok {
-				fmt.Println("Empty user input is not accepted")
-				continue
-			}
-			conversation = append(conversation, genai.Part{Text: userInput})
-			readUserInput = false // Only set to false after successful input
-		}
+	response := &Response{Usage: geminiUsage(result.UsageMetadata)}
+	if len(result.Candidates) > 0 && result.Candidates[0].Content != nil {
+		response.Parts = result.Candidates[0].Content.Parts
+	}
+	return response, nil
+}
 
-		if len(conversation) != 0 {
-			response, err := chat.SendMessage(ctx, conversation...)
+func (s *geminiSession) SendMessageStream(ctx context.Context, parts ...genai.Part) iter.Seq2[*Response, error] {
+	return func(yield func(*Response, error) bool) {
+		for result, err := range s.chat.SendMessageStream(ctx, parts...) {
 			if err != nil {
-				fmt.Printf("Error sending message: %v. Try again\n", err)
-				readUserInput = true // Reset to allow user input again
-				continue
+				yield(nil, err)
+				return
 			}
 
-			if len(response.Candidates) == 0 {
-				fmt.Println("No response from Gemini")
-				readUserInput = true
-				continue
+			chunk := &Response{Usage: geminiUsage(result.UsageMetadata)}
+			if len(result.Candidates) > 0 && result.Candidates[0].Content != nil {
+				chunk.Parts = result.Candidates[0].Content.Parts
 			}
-
-			content := response.Candidates[0].Content
-			numParts := len(content.Parts)
-			if numParts == 0 {
-				fmt.Println("I need more context")
-				readUserInput = true
-				continue
+			if !yield(chunk, nil) {
+				return
 			}
+		}
+	}
+}
 
-			conversation = nil
-			hasToolCalls := false
-
-			for _, part := range content.Parts {
-				if len(part.Text) != 0 {
-					fmt.Printf("\u001b[93mGemini\u001b[0m: %s\n", part.Text)
-				} else if part.FunctionCall != nil {
-					a.Logger.Info("Executing tool", "tool_name", part.FunctionCall.Name, "prompt", part.FunctionCall.Args)
-					resp := a.Tools.ExecuteTool(part.FunctionCall)
-
-					if output, ok := resp.Response["output"].(string); ok {
-						fmt.Printf("\n\033[1;94m[Tool Output]\033[0m\n%s\n", colorizeDiff(output))
-					} else if errMsg, ok := resp.Response["error"].(string); ok {
-						fmt.Printf("\n\033[1;91m[Tool Error]\033[0m %s\n", errMsg)
-					}
-					conversation = append(conversation, genai.Part{FunctionResponse: resp})
-					hasToolCalls = true
-				}
-			}
+func (s *geminiSession) History() []*genai.Content {
+	return s.chat.History(false)
+}
 
-			readUserInput = !hasToolCalls
-		}
+func geminiUsage(md *genai.GenerateContentResponseUsageMetadata) Usage {
+	if md == nil {
+		return Usage{}
+	}
+	return Usage{
+		InputTokens:  int(md.PromptTokenCount),
+		OutputTokens: int(md.CandidatesTokenCount),
+		TotalTokens:  int(md.TotalTokenCount),
 	}
 }
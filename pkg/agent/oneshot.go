@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+// DefaultMaxTurns bounds the number of model requests made by RunOnce.
+const DefaultMaxTurns = 50
+
+// ErrMaxTurns is returned by RunOnce when the model keeps calling tools after
+// the turn limit is reached.
+var ErrMaxTurns = errors.New("maximum number of turns reached")
+
+// Result is the outcome of a non-interactive run.
+type Result struct {
+	Text      string     `json:"text"`
+	ToolCalls []ToolCall `json:"tool_calls"`
+	Usage     Usage      `json:"usage"`
+}
+
+// ToolCall records a tool executed during a non-interactive run.
+type ToolCall struct {
+	Name     string         `json:"name"`
+	Args     map[string]any `json:"args"`
+	Response map[string]any `json:"response"`
+}
+
+/*
+RunOnce sends a single prompt and runs the tool loop to completion without asking for user input.
+
+It returns the final text of the model together with every tool call executed along the way. At most maxTurns requests are sent to the model; a value of zero or less uses DefaultMaxTurns.
+*/
+func (a *Agent) RunOnce(ctx context.Context, chat ChatSession, prompt string, maxTurns int) (*Result, error) {
+	if maxTurns <= 0 {
+		maxTurns = DefaultMaxTurns
+	}
+	defer a.saveSession(chat)
+
+	result := &Result{ToolCalls: []ToolCall{}}
+	conversation := []genai.Part{{Text: prompt}}
+	for turn := 0; turn < maxTurns; turn++ {
+		response, err := chat.SendMessage(ctx, conversation...)
+		if err != nil {
+			return result, fmt.Errorf("failed to send message: %w", err)
+		}
+		result.Usage.InputTokens += response.Usage.InputTokens
+		result.Usage.OutputTokens += response.Usage.OutputTokens
+		result.Usage.TotalTokens += response.Usage.TotalTokens
+
+		if text := response.Text(); text != "" {
+			result.Text = text
+		}
+
+		calls := response.FunctionCalls()
+		if len(calls) == 0 {
+			return result, nil
+		}
+
+		conversation = nil
+		for _, call := range calls {
+			a.Logger.Info("Executing tool", "tool_name", call.Name, "prompt", call.Args)
+			resp := a.Tools.ExecuteTool(ctx, call)
+			result.ToolCalls = append(result.ToolCalls, ToolCall{Name: call.Name, Args: call.Args, Response: resp.Response})
+			conversation = append(conversation, genai.Part{FunctionResponse: resp})
+		}
+	}
+	return result, ErrMaxTurns
+}
@@ -0,0 +1,389 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"cooder-assist/pkg/tools"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const (
+	defaultOpenAIBaseURL   = "https://api.openai.com/v1"
+	defaultOpenAIAPIKeyEnv = "OPENAI_API_KEY"
+)
+
+// OpenAIProvider talks to any server implementing the OpenAI
+// /v1/chat/completions API with tool calling (OpenAI, llama.cpp server, vLLM,
+// Ollama, ...).
+type OpenAIProvider struct {
+	// BaseURL is the API root including the version, e.g. http://localhost:8080/v1.
+	BaseURL string
+	// APIKey is sent as a bearer token when not empty.
+	APIKey            string
+	Model             string
+	SystemInstruction string
+	Tools             []openAITool
+	HTTPClient        *http.Client
+}
+
+// NewOpenAIProvider creates a provider for an OpenAI-compatible endpoint. The
+// API key is read from the apiKeyEnv environment variable; local servers that
+// do not need one may leave it unset.
+func NewOpenAIProvider(baseURL, apiKeyEnv, model, systemInstruction string, t *tools.Tools) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	if apiKeyEnv == "" {
+		apiKeyEnv = defaultOpenAIAPIKeyEnv
+	}
+
+	var defs []openAITool
+	for _, decl := range functionDeclarations(t) {
+		defs = append(defs, openAITool{
+			Type: "function",
+			Function: openAIFunction{
+				Name:        decl.Name,
+				Description: decl.Description,
+				Parameters:  jsonSchema(decl.Parameters),
+			},
+		})
+	}
+
+	return &OpenAIProvider{
+		BaseURL:           strings.TrimSuffix(baseURL, "/"),
+		APIKey:            os.Getenv(apiKeyEnv),
+		Model:             model,
+		SystemInstruction: systemInstruction,
+		Tools:             defs,
+		HTTPClient:        http.DefaultClient,
+	}
+}
+
+func (p *OpenAIProvider) NewSession(ctx context.Context, history []*genai.Content) (ChatSession, error) {
+	return &openAISession{provider: p, history: history}, nil
+}
+
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIRequest struct {
+	Model         string          `json:"model"`
+	Messages      []openAIMessage `json:"messages"`
+	Tools         []openAITool    `json:"tools,omitempty"`
+	Stream        bool            `json:"stream,omitempty"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage openAIUsage `json:"usage"`
+}
+
+// openAIStreamChunk is a single "data:" event of a streamed completion.
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type openAISession struct {
+	provider *OpenAIProvider
+	history  []*genai.Content
+}
+
+func (s *openAISession) History() []*genai.Content {
+	return s.history
+}
+
+func (s *openAISession) SendMessage(ctx context.Context, parts ...genai.Part) (*Response, error) {
+	input := userContent(parts)
+	req := s.request(input)
+	var result openAIResponse
+	if err := s.provider.post(ctx, "/chat/completions", req, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("openai: response has no choices")
+	}
+
+	output, err := openAIOutput(result.Choices[0].Message)
+	if err != nil {
+		return nil, err
+	}
+	s.history = append(s.history, input, output)
+	return &Response{Parts: output.Parts, Usage: result.Usage.toUsage()}, nil
+}
+
+func (s *openAISession) SendMessageStream(ctx context.Context, parts ...genai.Part) iter.Seq2[*Response, error] {
+	return func(yield func(*Response, error) bool) {
+		input := userContent(parts)
+		req := s.request(input)
+		req.Stream = true
+		req.StreamOptions = &struct {
+			IncludeUsage bool `json:"include_usage"`
+		}{IncludeUsage: true}
+
+		resp, err := s.provider.do(ctx, "/chat/completions", req)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		// Text is yielded as it arrives while tool call fragments are
+		// accumulated by index and yielded once the stream is done.
+		var text strings.Builder
+		var calls []openAIToolCall
+		var usage Usage
+		stopped := false
+		err = readSSE(resp.Body, func(_, data string) error {
+			if data == "[DONE]" {
+				return io.EOF
+			}
+			var chunk openAIStreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return fmt.Errorf("openai: failed to decode stream chunk: %w", err)
+			}
+			if chunk.Usage != nil {
+				usage = chunk.Usage.toUsage()
+			}
+			for _, choice := range chunk.Choices {
+				for _, delta := range choice.Delta.ToolCalls {
+					for len(calls) <= delta.Index {
+						calls = append(calls, openAIToolCall{Type: "function"})
+					}
+					call := &calls[delta.Index]
+					if delta.ID != "" {
+						call.ID = delta.ID
+					}
+					call.Function.Name += delta.Function.Name
+					call.Function.Arguments += delta.Function.Arguments
+				}
+				if choice.Delta.Content != "" {
+					text.WriteString(choice.Delta.Content)
+					if !yield(&Response{Parts: []*genai.Part{{Text: choice.Delta.Content}}}, nil) {
+						stopped = true
+						return io.EOF
+					}
+				}
+			}
+			return nil
+		})
+		if stopped {
+			return
+		}
+		if err != nil && err != io.EOF {
+			yield(nil, fmt.Errorf("openai: stream failed: %w", err))
+			return
+		}
+
+		str := text.String()
+		output, err := openAIOutput(openAIMessage{Role: "assistant", Content: &str, ToolCalls: calls})
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		s.history = append(s.history, input, output)
+		yield(&Response{Parts: functionCallParts(output.Parts), Usage: usage}, nil)
+	}
+}
+
+func (s *openAISession) request(input *genai.Content) openAIRequest {
+	return openAIRequest{
+		Model:    s.provider.Model,
+		Messages: openAIMessages(s.provider.SystemInstruction, append(s.history, input)),
+		Tools:    s.provider.Tools,
+	}
+}
+
+// openAIOutput converts an assistant message into a model turn.
+func openAIOutput(msg openAIMessage) (*genai.Content, error) {
+	output := &genai.Content{Role: genai.RoleModel}
+	if msg.Content != nil && *msg.Content != "" {
+		output.Parts = append(output.Parts, &genai.Part{Text: *msg.Content})
+	}
+	for _, call := range msg.ToolCalls {
+		args := map[string]any{}
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("openai: invalid arguments for tool call '%s': %w", call.Function.Name, err)
+			}
+		}
+		output.Parts = append(output.Parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+			ID:   call.ID,
+			Name: call.Function.Name,
+			Args: args,
+		}})
+	}
+	return output, nil
+}
+
+func (u openAIUsage) toUsage() Usage {
+	return Usage{
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+		TotalTokens:  u.TotalTokens,
+	}
+}
+
+// openAIMessages converts the genai history into chat completion messages.
+// Function responses become "tool" messages linked to their call by ID.
+func openAIMessages(systemInstruction string, history []*genai.Content) []openAIMessage {
+	var messages []openAIMessage
+	if systemInstruction != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: &systemInstruction})
+	}
+
+	for _, content := range history {
+		if content.Role == genai.RoleModel {
+			msg := openAIMessage{Role: "assistant"}
+			var text strings.Builder
+			for _, part := range content.Parts {
+				text.WriteString(part.Text)
+				if part.FunctionCall != nil {
+					args, _ := json.Marshal(part.FunctionCall.Args)
+					call := openAIToolCall{ID: toolCallID(part.FunctionCall.ID, part.FunctionCall.Name, len(msg.ToolCalls)), Type: "function"}
+					call.Function.Name = part.FunctionCall.Name
+					call.Function.Arguments = string(args)
+					msg.ToolCalls = append(msg.ToolCalls, call)
+				}
+			}
+			if text.Len() > 0 {
+				str := text.String()
+				msg.Content = &str
+			}
+			messages = append(messages, msg)
+			continue
+		}
+
+		var text strings.Builder
+		responses := 0
+		for _, part := range content.Parts {
+			text.WriteString(part.Text)
+			if part.FunctionResponse != nil {
+				body, _ := json.Marshal(part.FunctionResponse.Response)
+				str := string(body)
+				messages = append(messages, openAIMessage{
+					Role:       "tool",
+					Content:    &str,
+					ToolCallID: toolCallID(part.FunctionResponse.ID, part.FunctionResponse.Name, responses),
+				})
+				responses++
+			}
+		}
+		if text.Len() > 0 {
+			str := text.String()
+			messages = append(messages, openAIMessage{Role: "user", Content: &str})
+		}
+	}
+	return messages
+}
+
+// toolCallID returns the ID linking a function call to its response. Calls
+// recorded without an ID (e.g. by Gemini) fall back to one derived from the
+// name and index, the position of the call among the calls of its turn or of
+// the response among the responses of its turn. Responses are sent in the
+// order of the calls, so the two match up.
+func toolCallID(id, name string, index int) string {
+	if id != "" {
+		return id
+	}
+	return fmt.Sprintf("call_%d_%s", index, name)
+}
+
+func (p *OpenAIProvider) post(ctx context.Context, path string, body, out any) error {
+	resp, err := p.do(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("openai: failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("openai: failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// do sends the request and returns the response when the status is 200 OK.
+func (p *OpenAIProvider) do(ctx context.Context, path string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("openai: failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("openai: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("openai: request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("openai: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,207 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// openAIServer serves the replies in order, recording the requests it got.
+func openAIServer(t *testing.T, contentType string, replies []string) (*OpenAIProvider, func() []openAIRequest) {
+	var mu sync.Mutex
+	var requests []openAIRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req openAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(requests) >= len(replies) {
+			http.Error(w, "unexpected request", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(replies[len(requests)]))
+		requests = append(requests, req)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := &OpenAIProvider{BaseURL: srv.URL, Model: "test-model", HTTPClient: srv.Client()}
+	return p, func() []openAIRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+// sseEvents joins the payloads into a server-sent event stream.
+func sseEvents(payloads ...string) string {
+	var b strings.Builder
+	for _, p := range payloads {
+		b.WriteString("data: " + p + "\n\n")
+	}
+	return b.String()
+}
+
+func TestOpenAIToolCalls(t *testing.T) {
+	tests := []struct {
+		name        string
+		stream      bool
+		contentType string
+		replies     []string
+	}{
+		{
+			name:        "plain",
+			contentType: "application/json",
+			replies: []string{
+				`{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[
+					{"id":"call_a","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"a.go\"}"}},
+					{"id":"call_b","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"b.go\"}"}}
+				]}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+				`{"choices":[{"message":{"role":"assistant","content":"done"}}],"usage":{"prompt_tokens":20,"completion_tokens":1,"total_tokens":21}}`,
+			},
+		},
+		{
+			name:        "streamed",
+			stream:      true,
+			contentType: "text/event-stream",
+			replies: []string{
+				sseEvents(
+					`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"read_file","arguments":""}}]}}]}`,
+					`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+					`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","function":{"name":"read_file","arguments":"{\"path\":\"b.go\"}"}}]}}]}`,
+					`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.go\"}"}}]}}]}`,
+					`{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+					`[DONE]`,
+				),
+				sseEvents(
+					`{"choices":[{"delta":{"content":"do"}}]}`,
+					`{"choices":[{"delta":{"content":"ne"}}]}`,
+					`{"choices":[],"usage":{"prompt_tokens":20,"completion_tokens":1,"total_tokens":21}}`,
+					`[DONE]`,
+				),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, requests := openAIServer(t, tt.contentType, tt.replies)
+			chat, err := p.NewSession(context.Background(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			send := func(parts ...genai.Part) *Response {
+				t.Helper()
+				if !tt.stream {
+					resp, err := chat.SendMessage(context.Background(), parts...)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return resp
+				}
+				merged := &Response{}
+				for resp, err := range chat.SendMessageStream(context.Background(), parts...) {
+					if err != nil {
+						t.Fatal(err)
+					}
+					merged.Parts = append(merged.Parts, resp.Parts...)
+					if resp.Usage != (Usage{}) {
+						merged.Usage = resp.Usage
+					}
+				}
+				return merged
+			}
+
+			resp := send(genai.Part{Text: "read both files"})
+			want := []*genai.FunctionCall{
+				{ID: "call_a", Name: "read_file", Args: map[string]any{"path": "a.go"}},
+				{ID: "call_b", Name: "read_file", Args: map[string]any{"path": "b.go"}},
+			}
+			if got := resp.FunctionCalls(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("function calls = %+v, want %+v", got, want)
+			}
+			if want := (Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}); resp.Usage != want {
+				t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+			}
+
+			var responses []genai.Part
+			for _, call := range resp.FunctionCalls() {
+				responses = append(responses, genai.Part{FunctionResponse: &genai.FunctionResponse{
+					ID:       call.ID,
+					Name:     call.Name,
+					Response: map[string]any{"output": call.Args["path"]},
+				}})
+			}
+			resp = send(responses...)
+			if got := resp.Text(); got != "done" {
+				t.Errorf("text = %q, want %q", got, "done")
+			}
+
+			reqs := requests()
+			if len(reqs) != 2 {
+				t.Fatalf("got %d requests, want 2", len(reqs))
+			}
+			for _, req := range reqs {
+				if req.Stream != tt.stream {
+					t.Errorf("stream = %v, want %v", req.Stream, tt.stream)
+				}
+			}
+			// The second request replays the calls and answers each by ID.
+			msgs := reqs[1].Messages
+			if len(msgs) != 4 {
+				t.Fatalf("got %d messages, want 4: %+v", len(msgs), msgs)
+			}
+			var callIDs []string
+			for _, call := range msgs[1].ToolCalls {
+				callIDs = append(callIDs, call.ID)
+			}
+			if want := []string{"call_a", "call_b"}; !reflect.DeepEqual(callIDs, want) {
+				t.Errorf("assistant tool call IDs = %v, want %v", callIDs, want)
+			}
+			for i, id := range []string{"call_a", "call_b"} {
+				if msg := msgs[2+i]; msg.Role != "tool" || msg.ToolCallID != id {
+					t.Errorf("message %d = %s for %q, want a tool message for %q", 2+i, msg.Role, msg.ToolCallID, id)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAIMessagesWithoutCallIDs(t *testing.T) {
+	history := []*genai.Content{
+		{Role: genai.RoleModel, Parts: []*genai.Part{
+			{FunctionCall: &genai.FunctionCall{Name: "read_file", Args: map[string]any{"path": "a.go"}}},
+			{FunctionCall: &genai.FunctionCall{Name: "read_file", Args: map[string]any{"path": "b.go"}}},
+		}},
+		{Role: genai.RoleUser, Parts: []*genai.Part{
+			{FunctionResponse: &genai.FunctionResponse{Name: "read_file", Response: map[string]any{"output": "a"}}},
+			{FunctionResponse: &genai.FunctionResponse{Name: "read_file", Response: map[string]any{"output": "b"}}},
+		}},
+	}
+	msgs := openAIMessages("", history)
+	if len(msgs) != 3 {
+		t.Fatalf("got %d messages, want 3: %+v", len(msgs), msgs)
+	}
+	want := []string{"call_0_read_file", "call_1_read_file"}
+	for i, id := range want {
+		if got := msgs[0].ToolCalls[i].ID; got != id {
+			t.Errorf("call %d ID = %q, want %q", i, got, id)
+		}
+		if got := msgs[1+i].ToolCallID; got != id {
+			t.Errorf("response %d ID = %q, want %q", i, got, id)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"cooder-assist/pkg/config"
+	"cooder-assist/pkg/tools"
+	"fmt"
+	"iter"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Provider is an LLM backend able to start chat sessions.
+//
+// Conversations are exchanged as genai parts regardless of the backend, so the
+// tool declarations, function calls and function responses used by the Run
+// loop stay the same for every provider.
+type Provider interface {
+	// NewSession starts a chat seeded with the given history.
+	NewSession(ctx context.Context, history []*genai.Content) (ChatSession, error)
+}
+
+// ChatSession is a multi-turn conversation with a Provider.
+type ChatSession interface {
+	// SendMessage sends the parts as a user turn and returns the model reply.
+	SendMessage(ctx context.Context, parts ...genai.Part) (*Response, error)
+	// SendMessageStream sends the parts as a user turn and yields the reply in
+	// chunks as it is generated. Text arrives incrementally; function calls are
+	// yielded whole. The turn is only recorded in the history once the stream
+	// completes.
+	SendMessageStream(ctx context.Context, parts ...genai.Part) iter.Seq2[*Response, error]
+	// History returns every turn exchanged so far.
+	History() []*genai.Content
+}
+
+// Response is a single model reply.
+type Response struct {
+	// Parts holds the text and function call parts in the order they were produced.
+	Parts []*genai.Part
+	Usage Usage
+}
+
+// Text returns the concatenated text parts of the response.
+func (r *Response) Text() string {
+	var sb strings.Builder
+	for _, part := range r.Parts {
+		sb.WriteString(part.Text)
+	}
+	return sb.String()
+}
+
+// FunctionCalls returns the function calls requested by the model.
+func (r *Response) FunctionCalls() []*genai.FunctionCall {
+	var calls []*genai.FunctionCall
+	for _, part := range r.Parts {
+		if part.FunctionCall != nil {
+			calls = append(calls, part.FunctionCall)
+		}
+	}
+	return calls
+}
+
+// userContent wraps the parts sent by the user into a single turn.
+func userContent(parts []genai.Part) *genai.Content {
+	input := &genai.Content{Role: genai.RoleUser}
+	for _, part := range parts {
+		input.Parts = append(input.Parts, &part)
+	}
+	return input
+}
+
+// functionCallParts returns only the function call parts.
+func functionCallParts(parts []*genai.Part) []*genai.Part {
+	var calls []*genai.Part
+	for _, part := range parts {
+		if part.FunctionCall != nil {
+			calls = append(calls, part)
+		}
+	}
+	return calls
+}
+
+// Usage reports the tokens consumed by a request.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+const (
+	ProviderGemini    = "gemini"
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+)
+
+// NewProvider returns the Provider selected by cfg.Provider. An empty provider
+// name selects Gemini.
+func NewProvider(ctx context.Context, cfg config.ModelConfig, systemInstruction string, t *tools.Tools) (Provider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", ProviderGemini:
+		return NewGeminiProvider(ctx, cfg.Model, systemInstruction, t)
+	case ProviderOpenAI:
+		return NewOpenAIProvider(cfg.BaseURL, cfg.APIKeyEnv, cfg.Model, systemInstruction, t), nil
+	case ProviderAnthropic:
+		return NewAnthropicProvider(cfg.BaseURL, cfg.APIKeyEnv, cfg.Model, systemInstruction, t), nil
+	default:
+		return nil, fmt.Errorf("unknown model provider '%s'", cfg.Provider)
+	}
+}
@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"cooder-assist/pkg/tools"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// jsonSchema converts a genai.Schema into the JSON Schema object expected by
+// OpenAI-style and Anthropic tool definitions.
+func jsonSchema(s *genai.Schema) map[string]any {
+	if s == nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+
+	out := map[string]any{}
+	if s.Type != "" {
+		out["type"] = strings.ToLower(string(s.Type))
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Items != nil {
+		out["items"] = jsonSchema(s.Items)
+	}
+	if s.Type == genai.TypeObject {
+		props := map[string]any{}
+		for name, prop := range s.Properties {
+			props[name] = jsonSchema(prop)
+		}
+		out["properties"] = props
+		if len(s.Required) > 0 {
+			out["required"] = s.Required
+		}
+	}
+	return out
+}
+
+// functionDeclarations flattens the declarations of every tool.
+func functionDeclarations(t *tools.Tools) []*genai.FunctionDeclaration {
+	var decls []*genai.FunctionDeclaration
+	for _, tool := range t.Declarations() {
+		decls = append(decls, tool.FunctionDeclarations...)
+	}
+	return decls
+}
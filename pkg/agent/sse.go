@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// readSSE parses a server-sent events stream and calls fn for every event
+// until the stream ends or fn returns an error.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	scn := bufio.NewScanner(r)
+	scn.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	var event string
+	var data []string
+	for scn.Scan() {
+		line := scn.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				if err := fn(event, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// comment / keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scn.Err(); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		return fn(event, strings.Join(data, "\n"))
+	}
+	return nil
+}
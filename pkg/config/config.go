@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -18,10 +19,69 @@ const (
 
 type Config struct {
 	ModelConfig ModelConfig
+	Workspace   WorkspaceConfig
+	Commands    CommandsConfig
+	LSP         LSPConfig
 }
 
 type ModelConfig struct {
-	Model string
+	// Provider selects the LLM backend: "gemini", "openai" or "anthropic". Defaults to "gemini".
+	Provider string
+	Model    string
+	// BaseURL is the API root for HTTP based providers, e.g. http://localhost:8080/v1.
+	BaseURL string
+	// APIKeyEnv names the environment variable holding the API key.
+	APIKeyEnv string
+}
+
+// WorkspaceConfig confines the file tools to a directory tree.
+type WorkspaceConfig struct {
+	// Root is the directory file tools resolve paths against. Defaults to the current directory.
+	Root string
+	// ReadOnlyRoots are additional directories that may be read but not written.
+	ReadOnlyRoots []string
+	// MaxReadBytes caps the output of read_file. Defaults to 100KiB.
+	MaxReadBytes int
+	// Worktree runs every session in its own git worktree and branch, as
+	// the --worktree flag does.
+	Worktree bool
+}
+
+// CommandsConfig controls the run_command tool.
+type CommandsConfig struct {
+	// Allow lists command prefixes that run without approval, e.g. "go test".
+	Allow []string
+	// Deny lists command prefixes that are always refused, e.g. "git push".
+	Deny []string
+	// Timeout bounds the run time of a command, e.g. "5m". Defaults to 2 minutes.
+	Timeout time.Duration
+	// MaxOutputBytes caps stdout and stderr separately. Defaults to 64KiB.
+	MaxOutputBytes int
+	// Env lists the environment variables passed to commands. Defaults to
+	// PATH, HOME and the Go toolchain variables.
+	Env []string
+	// NoNetwork runs commands without network access (Linux only).
+	NoNetwork bool
+}
+
+// LSPConfig configures the language server behind the diagnostics,
+// go_to_definition and hover tools. It is started on first use.
+type LSPConfig struct {
+	// Command starts a language server speaking LSP over stdio. Defaults to "gopls".
+	Command string
+	// Args are passed to Command, e.g. ["serve"].
+	Args []string
+	// Extensions are the file extensions the server handles. Defaults to [".go"].
+	Extensions []string
+	// LanguageID is the LSP language id of those files. Defaults to "go".
+	LanguageID string
+	// Timeout bounds requests and waiting for diagnostics, e.g. "30s". Defaults to 20 seconds.
+	Timeout time.Duration
+	// AppendDiagnostics appends the errors and warnings in the files an edit
+	// tool wrote to its response.
+	AppendDiagnostics bool
+	// Disabled turns the language server tools off.
+	Disabled bool
 }
 
 func InitConfig(cfgFile string, cfgPath string) (Config, error) {
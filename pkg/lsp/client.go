@@ -0,0 +1,654 @@
+// Package lsp is a client for language servers speaking the Language Server
+// Protocol over stdio, such as gopls.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCommand  = "gopls"
+	defaultTimeout  = 20 * time.Second
+	shutdownTimeout = 5 * time.Second
+)
+
+// Config describes the language server of a workspace.
+type Config struct {
+	// Command starts the server, which must speak LSP on stdin and stdout.
+	// Defaults to "gopls".
+	Command string
+	Args    []string
+	// Extensions are the file extensions the server handles. Defaults to
+	// ".go".
+	Extensions []string
+	// LanguageID is the LSP language id of the files. Defaults to "go".
+	LanguageID string
+	// Timeout bounds requests and waiting for diagnostics. Defaults to 20
+	// seconds.
+	Timeout time.Duration
+}
+
+// Client is a language server for the files below a root directory. The
+// server is started on first use and restarted if it exits. Files are opened
+// in the server when they are queried, and Sync keeps them up to date.
+type Client struct {
+	root   string
+	config Config
+
+	// opMu serialises starting the server and changing documents, so the
+	// server sees the changes in order.
+	opMu sync.Mutex
+	srv  *server
+	docs map[string]*document
+
+	// mu guards the diagnostics published by the server.
+	mu        sync.Mutex
+	diags     map[string]*published
+	seq       uint64
+	published chan struct{}
+}
+
+// document is a file opened in the server.
+type document struct {
+	version int
+	text    string
+	// seq is the number of diagnostics notifications received before the
+	// server was sent this version.
+	seq uint64
+}
+
+// published are the diagnostics of a file, as last published.
+type published struct {
+	version     int
+	seq         uint64
+	diagnostics []Diagnostic
+}
+
+// Report is the result of Diagnostics.
+type Report struct {
+	// Files maps every file with diagnostics to them.
+	Files map[string][]Diagnostic
+	// Pending are the requested files the server published no diagnostics
+	// for in time; their entries may be stale.
+	Pending []string
+}
+
+// New returns a client for the language server of the workspace at root.
+func New(root string, config Config) *Client {
+	if config.Command == "" {
+		config.Command = defaultCommand
+	}
+	if len(config.Extensions) == 0 {
+		config.Extensions = []string{".go"}
+	}
+	if config.LanguageID == "" {
+		config.LanguageID = "go"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Client{
+		root:      root,
+		config:    config,
+		docs:      make(map[string]*document),
+		diags:     make(map[string]*published),
+		published: make(chan struct{}),
+	}
+}
+
+// Command returns the command line the server is started with.
+func (c *Client) Command() string {
+	return strings.Join(append([]string{c.config.Command}, c.config.Args...), " ")
+}
+
+// Handles reports whether the server handles the file at path.
+func (c *Client) Handles(path string) bool {
+	return slices.Contains(c.config.Extensions, filepath.Ext(path))
+}
+
+// Sync tells a running server that the files at paths changed on disk. Open
+// files are sent their new content; the server reads the others itself.
+func (c *Client) Sync(paths []string) {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+	if c.srv == nil || !c.srv.running() {
+		return
+	}
+	var events []fileEvent
+	for _, path := range paths {
+		if !c.Handles(path) {
+			continue
+		}
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			if c.docs[path] != nil {
+				c.srv.notify("textDocument/didClose", map[string]any{"textDocument": textDocumentIdentifier{URI: fileURI(path)}})
+				delete(c.docs, path)
+			}
+			c.mu.Lock()
+			delete(c.diags, path)
+			c.mu.Unlock()
+			events = append(events, fileEvent{URI: fileURI(path), Type: fileDeleted})
+			continue
+		}
+		if c.docs[path] != nil {
+			c.open(path)
+			continue
+		}
+		events = append(events, fileEvent{URI: fileURI(path), Type: fileChanged})
+	}
+	if len(events) > 0 {
+		c.srv.notify("workspace/didChangeWatchedFiles", map[string]any{"changes": events})
+	}
+}
+
+// Diagnostics opens the files at paths, waits for the server to publish
+// their diagnostics and returns every diagnostic it has published.
+func (c *Client) Diagnostics(ctx context.Context, paths []string) (*Report, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	srv, err := c.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opened := make([]document, len(paths))
+	c.opMu.Lock()
+	for i, path := range paths {
+		doc, err := c.open(path)
+		if err != nil {
+			c.opMu.Unlock()
+			return nil, err
+		}
+		opened[i] = *doc
+	}
+	c.opMu.Unlock()
+
+	report := &Report{Files: make(map[string][]Diagnostic)}
+	for i, path := range paths {
+		if err := c.waitDiagnostics(ctx, srv, path, opened[i]); err != nil {
+			if ctx.Err() == nil {
+				return nil, err
+			}
+			report.Pending = append(report.Pending, path)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path, p := range c.diags {
+		if len(p.diagnostics) > 0 {
+			report.Files[path] = slices.Clone(p.diagnostics)
+		}
+	}
+	return report, nil
+}
+
+// waitDiagnostics waits until the server publishes diagnostics for the
+// version of the file in doc.
+func (c *Client) waitDiagnostics(ctx context.Context, srv *server, path string, doc document) error {
+	for {
+		c.mu.Lock()
+		p := c.diags[path]
+		published := c.published
+		c.mu.Unlock()
+		if p != nil && p.seq > doc.seq && (p.version == 0 || p.version >= doc.version) {
+			return nil
+		}
+		select {
+		case <-published:
+		case <-srv.done:
+			return srv.exitError()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Definition returns the locations where the identifier at pos in the file
+// at path is declared.
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var result json.RawMessage
+	if err := c.query(ctx, "textDocument/definition", path, pos, &result); err != nil {
+		return nil, err
+	}
+	return parseLocations(result)
+}
+
+// Hover returns the hover text of the identifier at pos in the file at path,
+// usually its declaration and documentation in Markdown. It is empty when the
+// server has nothing to show.
+func (c *Client) Hover(ctx context.Context, path string, pos Position) (string, error) {
+	var result *struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := c.query(ctx, "textDocument/hover", path, pos, &result); err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", nil
+	}
+	return parseHover(result.Contents), nil
+}
+
+// query opens the file at path and sends a request about a position in it.
+func (c *Client) query(ctx context.Context, method, path string, pos Position, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	srv, err := c.start(ctx)
+	if err != nil {
+		return err
+	}
+	c.opMu.Lock()
+	_, err = c.open(path)
+	c.opMu.Unlock()
+	if err != nil {
+		return err
+	}
+	params := textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: fileURI(path)}, Position: pos}
+	if err := srv.call(ctx, method, params, result); err != nil {
+		return fmt.Errorf("%s failed: %w", method, err)
+	}
+	return nil
+}
+
+// open sends the file at path to the server, opening it or sending its new
+// content when it changed on disk. opMu must be held.
+func (c *Client) open(path string) (*document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	doc := c.docs[path]
+	if doc != nil && doc.text == text {
+		return doc, nil
+	}
+
+	c.mu.Lock()
+	seq := c.seq
+	c.mu.Unlock()
+	uri := fileURI(path)
+	if doc == nil {
+		doc = &document{version: 1, text: text, seq: seq}
+		c.docs[path] = doc
+		return doc, c.srv.notify("textDocument/didOpen", map[string]any{
+			"textDocument": textDocumentItem{URI: uri, LanguageID: c.config.LanguageID, Version: doc.version, Text: text},
+		})
+	}
+	doc.version++
+	doc.text, doc.seq = text, seq
+	return doc, c.srv.notify("textDocument/didChange", map[string]any{
+		"textDocument":   versionedTextDocumentIdentifier{URI: uri, Version: doc.version},
+		"contentChanges": []map[string]string{{"text": text}},
+	})
+}
+
+// start returns the running server, starting it first if it is not running.
+func (c *Client) start(ctx context.Context) (*server, error) {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+	if c.srv != nil && c.srv.running() {
+		return c.srv, nil
+	}
+
+	srv, err := startServer(c.config, c.root, c.handle)
+	if err != nil {
+		return nil, err
+	}
+	// A restarted server knows no open documents.
+	c.srv = srv
+	c.docs = make(map[string]*document)
+	c.mu.Lock()
+	c.diags = make(map[string]*published)
+	c.mu.Unlock()
+
+	rootURI := fileURI(c.root)
+	params := map[string]any{
+		"processId":  os.Getpid(),
+		"clientInfo": map[string]string{"name": "cooder-assist"},
+		"rootUri":    rootURI,
+		"rootPath":   c.root,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": filepath.Base(c.root)},
+		},
+		"capabilities": map[string]any{
+			"workspace": map[string]any{
+				"workspaceFolders":       true,
+				"configuration":          true,
+				"didChangeWatchedFiles":  map[string]any{"dynamicRegistration": false},
+				"workspaceEdit":          map[string]any{"documentChanges": false},
+				"didChangeConfiguration": map[string]any{"dynamicRegistration": false},
+			},
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"dynamicRegistration": false},
+				"publishDiagnostics": map[string]any{"versionSupport": true},
+				"hover":              map[string]any{"contentFormat": []string{"markdown", "plaintext"}},
+				"definition":         map[string]any{"linkSupport": true},
+			},
+		},
+	}
+	if err := srv.call(ctx, "initialize", params, nil); err != nil {
+		srv.kill()
+		return nil, fmt.Errorf("failed to initialise language server '%s': %w", c.config.Command, err)
+	}
+	if err := srv.notify("initialized", map[string]any{}); err != nil {
+		srv.kill()
+		return nil, err
+	}
+	return srv, nil
+}
+
+// handle processes the requests and notifications the server sends.
+func (c *Client) handle(srv *server, msg *message) {
+	switch msg.Method {
+	case "textDocument/publishDiagnostics":
+		var params publishDiagnosticsParams
+		if json.Unmarshal(msg.Params, &params) != nil {
+			return
+		}
+		c.mu.Lock()
+		c.seq++
+		c.diags[uriPath(params.URI)] = &published{version: params.Version, seq: c.seq, diagnostics: params.Diagnostics}
+		close(c.published)
+		c.published = make(chan struct{})
+		c.mu.Unlock()
+	case "workspace/configuration":
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		srv.reply(msg.ID, make([]any, len(params.Items)), nil)
+	case "window/workDoneProgress/create", "client/registerCapability", "client/unregisterCapability":
+		srv.reply(msg.ID, nil, nil)
+	case "workspace/applyEdit":
+		srv.reply(msg.ID, map[string]any{"applied": false, "failureReason": "edits are made by the agent's tools"}, nil)
+	default:
+		if msg.ID != nil {
+			srv.reply(msg.ID, nil, &ResponseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method})
+		}
+	}
+}
+
+// Close shuts the server down if it is running.
+func (c *Client) Close() error {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+	srv := c.srv
+	c.srv = nil
+	if srv == nil || !srv.running() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.call(ctx, "shutdown", nil, nil); err == nil {
+		srv.notify("exit", nil)
+	}
+	srv.stdin.Close()
+	select {
+	case <-srv.done:
+		return nil
+	case <-ctx.Done():
+		srv.kill()
+		return fmt.Errorf("language server '%s' did not exit, killed it", c.config.Command)
+	}
+}
+
+// server is a running language server process.
+type server struct {
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stderr  *tailBuffer
+	writeMu sync.Mutex
+
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	pending map[string]chan *message
+
+	// done is closed once the process exited; err tells why.
+	done chan struct{}
+	err  error
+}
+
+func startServer(config Config, dir string, handle func(*server, *message)) (*server, error) {
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Dir = dir
+	srv := &server{
+		command: config.Command,
+		cmd:     cmd,
+		stderr:  &tailBuffer{max: 4096},
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = srv.stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start language server '%s': %w", config.Command, err)
+	}
+	srv.stdin = stdin
+
+	go func() {
+		r := bufio.NewReader(stdout)
+		var readErr error
+		for {
+			msg, err := readMessage(r)
+			if err != nil {
+				readErr = err
+				break
+			}
+			if msg.Method == "" {
+				srv.deliver(msg)
+				continue
+			}
+			handle(srv, msg)
+		}
+		// Unblock the server if it is still writing, then reap it.
+		io.Copy(io.Discard, stdout)
+		waitErr := cmd.Wait()
+		switch {
+		case waitErr != nil:
+			srv.err = waitErr
+		case !errors.Is(readErr, io.EOF):
+			srv.err = readErr
+		default:
+			srv.err = errors.New("exited")
+		}
+		close(srv.done)
+	}()
+	return srv, nil
+}
+
+func (s *server) running() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// exitError describes why the server stopped, with the end of its stderr.
+func (s *server) exitError() error {
+	msg := fmt.Sprintf("language server '%s' stopped: %v", s.command, s.err)
+	if stderr := s.stderr.String(); stderr != "" {
+		msg += "\n" + stderr
+	}
+	return errors.New(msg)
+}
+
+// call sends a request and decodes its result into result, which may be nil.
+func (s *server) call(ctx context.Context, method string, params, result any) error {
+	id := json.RawMessage(strconv.FormatInt(s.nextID.Add(1), 10))
+	ch := make(chan *message, 1)
+	s.mu.Lock()
+	s.pending[string(id)] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, string(id))
+		s.mu.Unlock()
+	}()
+
+	if err := s.send(&message{ID: id, Method: method, Params: mustMarshal(params)}); err != nil {
+		return err
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	case <-s.done:
+		return s.exitError()
+	case <-ctx.Done():
+		s.notify("$/cancelRequest", map[string]any{"id": id})
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("language server '%s' did not answer in time", s.command)
+		}
+		return ctx.Err()
+	}
+}
+
+// deliver passes a response to the call waiting for it.
+func (s *server) deliver(msg *message) {
+	s.mu.Lock()
+	ch := s.pending[string(msg.ID)]
+	s.mu.Unlock()
+	if ch != nil {
+		ch <- msg
+	}
+}
+
+func (s *server) notify(method string, params any) error {
+	return s.send(&message{Method: method, Params: mustMarshal(params)})
+}
+
+func (s *server) reply(id json.RawMessage, result any, respErr *ResponseError) error {
+	msg := &message{ID: id, Error: respErr}
+	if respErr == nil {
+		// A null result must still be sent as such.
+		msg.Result = mustMarshal(result)
+		if msg.Result == nil {
+			msg.Result = json.RawMessage("null")
+		}
+	}
+	return s.send(msg)
+}
+
+func (s *server) send(msg *message) error {
+	if !s.running() {
+		return errNotRunning
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := writeMessage(s.stdin, msg); err != nil {
+		return fmt.Errorf("failed to write to language server '%s': %w", s.command, err)
+	}
+	return nil
+}
+
+func (s *server) kill() {
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+}
+
+// mustMarshal encodes the params of a message; a nil value is omitted.
+func mustMarshal(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// parseLocations decodes a definition result: null, a Location, or a list
+// of Locations or LocationLinks.
+func parseLocations(result json.RawMessage) ([]Location, error) {
+	var raws []json.RawMessage
+	switch trimmed := strings.TrimSpace(string(result)); {
+	case trimmed == "" || trimmed == "null":
+		return nil, nil
+	case strings.HasPrefix(trimmed, "["):
+		if err := json.Unmarshal(result, &raws); err != nil {
+			return nil, err
+		}
+	default:
+		raws = []json.RawMessage{result}
+	}
+	var locations []Location
+	for _, raw := range raws {
+		var loc struct {
+			location
+			locationLink
+		}
+		if err := json.Unmarshal(raw, &loc); err != nil {
+			return nil, fmt.Errorf("invalid location: %w", err)
+		}
+		if loc.TargetURI != "" {
+			locations = append(locations, Location{Path: uriPath(loc.TargetURI), Range: loc.TargetSelectionRange})
+		} else {
+			locations = append(locations, Location{Path: uriPath(loc.URI), Range: loc.Range})
+		}
+	}
+	return locations, nil
+}
+
+// parseHover decodes hover contents: MarkupContent, a MarkedString or a list
+// of MarkedStrings.
+func parseHover(contents json.RawMessage) string {
+	var list []json.RawMessage
+	if json.Unmarshal(contents, &list) == nil {
+		parts := make([]string, 0, len(list))
+		for _, item := range list {
+			if s := parseHover(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	var s string
+	if json.Unmarshal(contents, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	var marked struct {
+		markupContent
+		Language string `json:"language"`
+	}
+	if json.Unmarshal(contents, &marked) != nil {
+		return ""
+	}
+	if marked.Language != "" {
+		return fmt.Sprintf("```%s\n%s\n```", marked.Language, strings.TrimSpace(marked.Value))
+	}
+	return strings.TrimSpace(marked.Value)
+}
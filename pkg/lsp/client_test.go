@@ -0,0 +1,416 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fake server is the test binary itself, re-executed with
+// fakeServerEnv set to one of the modes handled by runFakeServer.
+const (
+	fakeServerEnv = "LSP_TEST_FAKE_SERVER"
+	fakeLogEnv    = "LSP_TEST_FAKE_LOG"
+)
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(fakeServerEnv); mode != "" {
+		runFakeServer(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeServer speaks LSP on stdin and stdout. It logs the messages it gets
+// to the file named by fakeLogEnv and publishes diagnostics for every open
+// or change, depending on mode:
+//
+//	publish  diagnostics for each version right away
+//	stale    diagnostics for the previous version first, then the current one
+//	silent   no diagnostics at all
+//
+// Definition and hover results have a different shape for each line of the
+// queried position; hover on line 9 makes the server exit.
+func runFakeServer(mode string) {
+	logFile, _ := os.OpenFile(os.Getenv(fakeLogEnv), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	logf := func(format string, args ...any) {
+		if logFile != nil {
+			fmt.Fprintf(logFile, format+"\n", args...)
+		}
+	}
+	send := func(msg *message) { writeMessage(os.Stdout, msg) }
+	publish := func(uri string, version int) {
+		send(&message{Method: "textDocument/publishDiagnostics", Params: mustMarshal(publishDiagnosticsParams{
+			URI:         uri,
+			Version:     version,
+			Diagnostics: []Diagnostic{{Severity: SeverityError, Message: fmt.Sprintf("v%d", version)}},
+		})})
+	}
+	diagnose := func(uri string, version int) {
+		switch mode {
+		case "publish":
+			publish(uri, version)
+		case "stale":
+			publish(uri, version-1)
+			time.Sleep(100 * time.Millisecond)
+			publish(uri, version)
+		}
+	}
+
+	r := bufio.NewReader(os.Stdin)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		var params struct {
+			TextDocument struct {
+				URI     string `json:"uri"`
+				Version int    `json:"version"`
+			} `json:"textDocument"`
+			Position Position    `json:"position"`
+			Changes  []fileEvent `json:"changes"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		name := filepath.Base(uriPath(params.TextDocument.URI))
+
+		switch msg.Method {
+		case "initialize":
+			logf("initialize")
+			send(&message{ID: msg.ID, Result: json.RawMessage(`{"capabilities":{}}`)})
+		case "textDocument/didOpen", "textDocument/didChange":
+			logf("%s %s %d", msg.Method, name, params.TextDocument.Version)
+			diagnose(params.TextDocument.URI, params.TextDocument.Version)
+		case "textDocument/didClose":
+			logf("%s %s", msg.Method, name)
+		case "workspace/didChangeWatchedFiles":
+			for _, change := range params.Changes {
+				logf("%s %s %d", msg.Method, filepath.Base(uriPath(change.URI)), change.Type)
+			}
+		case "textDocument/definition":
+			uri := params.TextDocument.URI
+			results := []string{
+				`{"uri":"` + uri + `","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":5}}}`,
+				`[{"uri":"` + uri + `","range":{"start":{"line":3,"character":0},"end":{"line":3,"character":1}}}]`,
+				`[{"targetUri":"` + uri + `","targetRange":{"start":{"line":4,"character":0},"end":{"line":9,"character":1}},"targetSelectionRange":{"start":{"line":5,"character":5},"end":{"line":5,"character":8}}}]`,
+				`null`,
+			}
+			send(&message{ID: msg.ID, Result: json.RawMessage(results[params.Position.Line])})
+		case "textDocument/hover":
+			if params.Position.Line == 9 {
+				os.Exit(3)
+			}
+			results := []string{
+				`{"contents":{"kind":"markdown","value":"func F()"}}`,
+				`{"contents":"plain text"}`,
+				`{"contents":{"language":"go","value":"var x int"}}`,
+				`{"contents":["doc",{"language":"go","value":"type T struct{}"}]}`,
+				`null`,
+			}
+			send(&message{ID: msg.ID, Result: json.RawMessage(results[params.Position.Line])})
+		case "shutdown":
+			send(&message{ID: msg.ID, Result: json.RawMessage("null")})
+		case "exit":
+			return
+		default:
+			if msg.ID != nil {
+				send(&message{ID: msg.ID, Result: json.RawMessage("null")})
+			}
+		}
+	}
+}
+
+// fakeClient returns a client running the fake server in mode, and a
+// function returning what the server logged so far.
+func fakeClient(t *testing.T, mode string, timeout time.Duration) (*Client, string, func() []string) {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "server.log")
+	t.Setenv(fakeServerEnv, mode)
+	t.Setenv(fakeLogEnv, logPath)
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(dir, Config{Command: exe, Timeout: timeout})
+	t.Cleanup(func() { c.Close() })
+	return c, dir, func() []string {
+		data, _ := os.ReadFile(logPath)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientSync(t *testing.T) {
+	c, dir, serverLog := fakeClient(t, "publish", 5*time.Second)
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	writeFile(t, a, "package a\n")
+	writeFile(t, b, "package a\n")
+	ctx := context.Background()
+
+	if _, err := c.Diagnostics(ctx, []string{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, a, "package a\n\nvar x int\n")
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	// c.go is not open, so the server is told to read it itself.
+	writeFile(t, filepath.Join(dir, "c.go"), "package a\n")
+	c.Sync([]string{a, b, filepath.Join(dir, "c.go"), filepath.Join(dir, "notes.txt")})
+	// Unchanged files are not sent again.
+	report, err := c.Diagnostics(ctx, []string{a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The server handles messages in order, so once a request is answered
+	// it has logged the notifications sent before it.
+	if _, err := c.Hover(ctx, a, Position{Line: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"initialize",
+		"textDocument/didOpen a.go 1",
+		"textDocument/didOpen b.go 1",
+		"textDocument/didChange a.go 2",
+		"textDocument/didClose b.go",
+		fmt.Sprintf("workspace/didChangeWatchedFiles b.go %d", fileDeleted),
+		fmt.Sprintf("workspace/didChangeWatchedFiles c.go %d", fileChanged),
+	}
+	if got := serverLog(); !reflect.DeepEqual(got, want) {
+		t.Errorf("server got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got := report.Files[a]; len(got) != 1 || got[0].Message != "v2" {
+		t.Errorf("diagnostics of a.go = %+v, want the ones of version 2", got)
+	}
+	if _, ok := report.Files[b]; ok {
+		t.Errorf("diagnostics of the deleted b.go are still reported")
+	}
+}
+
+func TestClientDiagnosticsWaitsForVersion(t *testing.T) {
+	c, dir, _ := fakeClient(t, "stale", 5*time.Second)
+	path := filepath.Join(dir, "a.go")
+	writeFile(t, path, "package a\n")
+	ctx := context.Background()
+	if _, err := c.Diagnostics(ctx, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, path, "package a\n\nvar x int\n")
+	c.Sync([]string{path})
+	report, err := c.Diagnostics(ctx, []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Pending) != 0 {
+		t.Errorf("pending = %v, want none", report.Pending)
+	}
+	if got := report.Files[path]; len(got) != 1 || got[0].Message != "v2" {
+		t.Errorf("diagnostics = %+v, want the ones of version 2", got)
+	}
+}
+
+func TestClientDiagnosticsPending(t *testing.T) {
+	c, dir, _ := fakeClient(t, "silent", 300*time.Millisecond)
+	path := filepath.Join(dir, "a.go")
+	writeFile(t, path, "package a\n")
+
+	report, err := c.Diagnostics(context.Background(), []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Pending, []string{path}) {
+		t.Errorf("pending = %v, want [%s]", report.Pending, path)
+	}
+}
+
+func TestClientDefinition(t *testing.T) {
+	c, dir, _ := fakeClient(t, "publish", 5*time.Second)
+	path := filepath.Join(dir, "a.go")
+	writeFile(t, path, "package a\n")
+
+	tests := []struct {
+		name string
+		line int
+		want []Location
+	}{
+		{name: "Location", line: 0, want: []Location{{Path: path, Range: Range{Position{1, 2}, Position{1, 5}}}}},
+		{name: "list of Locations", line: 1, want: []Location{{Path: path, Range: Range{Position{3, 0}, Position{3, 1}}}}},
+		{name: "LocationLink", line: 2, want: []Location{{Path: path, Range: Range{Position{5, 5}, Position{5, 8}}}}},
+		{name: "null", line: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Definition(context.Background(), path, Position{Line: tt.line})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Definition = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientHover(t *testing.T) {
+	c, dir, _ := fakeClient(t, "publish", 5*time.Second)
+	path := filepath.Join(dir, "a.go")
+	writeFile(t, path, "package a\n")
+
+	tests := []struct {
+		name string
+		line int
+		want string
+	}{
+		{name: "MarkupContent", line: 0, want: "func F()"},
+		{name: "string", line: 1, want: "plain text"},
+		{name: "MarkedString", line: 2, want: "```go\nvar x int\n```"},
+		{name: "list", line: 3, want: "doc\n\n```go\ntype T struct{}\n```"},
+		{name: "null", line: 4, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Hover(context.Background(), path, Position{Line: tt.line})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Hover = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRestart(t *testing.T) {
+	c, dir, serverLog := fakeClient(t, "publish", 5*time.Second)
+	path := filepath.Join(dir, "a.go")
+	writeFile(t, path, "package a\n")
+	ctx := context.Background()
+
+	_, err := c.Hover(ctx, path, Position{Line: 9})
+	if err == nil || !strings.Contains(err.Error(), "stopped") {
+		t.Fatalf("Hover on a crashing server: %v, want an error saying it stopped", err)
+	}
+	got, err := c.Hover(ctx, path, Position{Line: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "plain text" {
+		t.Errorf("Hover after restart = %q", got)
+	}
+	// The restarted server is sent the file again.
+	want := []string{"initialize", "textDocument/didOpen a.go 1", "initialize", "textDocument/didOpen a.go 1"}
+	if got := serverLog(); !reflect.DeepEqual(got, want) {
+		t.Errorf("server got %q, want %q", got, want)
+	}
+}
+
+func TestMessageFraming(t *testing.T) {
+	var buf bytes.Buffer
+	sent := &message{ID: json.RawMessage("7"), Method: "textDocument/hover", Params: json.RawMessage(`{"x":"é"}`)}
+	if err := writeMessage(&buf, sent); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMessage(&buf, &message{Method: "exit"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(&buf)
+	got, err := readMessage(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.ID) != "7" || got.Method != sent.Method || string(got.Params) != string(sent.Params) || got.JSONRPC != "2.0" {
+		t.Errorf("read %+v, want %+v", got, sent)
+	}
+	if got, err := readMessage(r); err != nil || got.Method != "exit" {
+		t.Errorf("second message = %+v, %v", got, err)
+	}
+
+	for _, bad := range []string{
+		"Content-Length: x\r\n\r\n{}",
+		"Content-Length: 10\r\n\r\n{}",
+		"Content-Length: 2\r\n\r\nno",
+	} {
+		if _, err := readMessage(bufio.NewReader(strings.NewReader(bad))); err == nil {
+			t.Errorf("readMessage(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestParseLocations(t *testing.T) {
+	rng := `{"start":{"line":1,"character":2},"end":{"line":1,"character":4}}`
+	sel := `{"start":{"line":7,"character":5},"end":{"line":7,"character":9}}`
+	want := Range{Position{1, 2}, Position{1, 4}}
+	tests := []struct {
+		name    string
+		result  string
+		want    []Location
+		wantErr bool
+	}{
+		{name: "empty", result: ""},
+		{name: "null", result: "null"},
+		{name: "empty list", result: "[]"},
+		{name: "Location", result: `{"uri":"file:///src/a.go","range":` + rng + `}`, want: []Location{{Path: "/src/a.go", Range: want}}},
+		{
+			name:   "list of Locations",
+			result: `[{"uri":"file:///src/a.go","range":` + rng + `},{"uri":"file:///src/b%20c.go","range":` + rng + `}]`,
+			want:   []Location{{Path: "/src/a.go", Range: want}, {Path: "/src/b c.go", Range: want}},
+		},
+		{
+			name:   "LocationLink uses the selection range",
+			result: `[{"targetUri":"file:///src/a.go","targetRange":` + rng + `,"targetSelectionRange":` + sel + `}]`,
+			want:   []Location{{Path: "/src/a.go", Range: Range{Position{7, 5}, Position{7, 9}}}},
+		},
+		{name: "invalid", result: `[1]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLocations(json.RawMessage(tt.result))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLocations = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHover(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{name: "MarkupContent", contents: `{"kind":"markdown","value":"  **F** docs\n"}`, want: "**F** docs"},
+		{name: "string", contents: `"plain"`, want: "plain"},
+		{name: "MarkedString", contents: `{"language":"go","value":"func F()"}`, want: "```go\nfunc F()\n```"},
+		{name: "list", contents: `["a","",{"language":"go","value":"b"}]`, want: "a\n\n```go\nb\n```"},
+		{name: "invalid", contents: `42`, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseHover(json.RawMessage(tt.contents)); got != tt.want {
+				t.Errorf("parseHover = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
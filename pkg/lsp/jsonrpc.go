@@ -0,0 +1,105 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxMessageBytes bounds the size of a message read from the server.
+const maxMessageBytes = 64 << 20
+
+// codeMethodNotFound is the JSON-RPC error code replied to server requests
+// the client does not implement.
+const codeMethodNotFound = -32601
+
+// message is a JSON-RPC 2.0 request, notification or response. Requests and
+// responses have an ID; notifications do not.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// ResponseError is an error response from the server.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// readMessage reads one message framed by a Content-Length header.
+func readMessage(r *bufio.Reader) (*message, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header '%s'", header.Get("Content-Length"))
+	}
+	if length > maxMessageBytes {
+		return nil, fmt.Errorf("message of %d bytes exceeds the limit of %d", length, maxMessageBytes)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return &msg, nil
+}
+
+// writeMessage frames msg with a Content-Length header.
+func writeMessage(w io.Writer, msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// tailBuffer keeps the last bytes written to it, to report what a server
+// printed to stderr before it failed.
+type tailBuffer struct {
+	max  int
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > b.max {
+		b.data = b.data[len(b.data)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.data))
+}
+
+var errNotRunning = errors.New("the language server is not running")
@@ -0,0 +1,173 @@
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// Position is a zero-based line and UTF-16 character offset in a document.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span of a document; End is exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a file.
+type Location struct {
+	Path  string
+	Range Range
+}
+
+// Severity is the severity of a Diagnostic.
+type Severity int
+
+const (
+	SeverityError       Severity = 1
+	SeverityWarning     Severity = 2
+	SeverityInformation Severity = 3
+	SeverityHint        Severity = 4
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "information"
+	case SeverityHint:
+		return "hint"
+	default:
+		return "error"
+	}
+}
+
+// Diagnostic is a problem the server reports in a file.
+type Diagnostic struct {
+	Range    Range           `json:"range"`
+	Severity Severity        `json:"severity"`
+	Code     json.RawMessage `json:"code,omitempty"`
+	Source   string          `json:"source,omitempty"`
+	Message  string          `json:"message"`
+}
+
+// CodeString returns the diagnostic code, which servers send as a number or
+// a string.
+func (d Diagnostic) CodeString() string {
+	var s string
+	if json.Unmarshal(d.Code, &s) == nil {
+		return s
+	}
+	return string(d.Code)
+}
+
+type location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type locationLink struct {
+	TargetURI            string `json:"targetUri"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
+}
+
+type textDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type versionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     int          `json:"version"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// File change types of workspace/didChangeWatchedFiles.
+const (
+	fileCreated = 1
+	fileChanged = 2
+	fileDeleted = 3
+)
+
+type fileEvent struct {
+	URI  string `json:"uri"`
+	Type int    `json:"type"`
+}
+
+// markupContent is the hover content of current servers; older ones send a
+// MarkedString, a string or {language, value}, or a list of them.
+type markupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// fileURI returns the file URI of an absolute path.
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// uriPath returns the path of a file URI, or the URI itself when it is not
+// one.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// Character returns the UTF-16 offset of the byte offset col in line.
+func Character(line string, col int) int {
+	n := 0
+	for i, r := range line {
+		if i >= col {
+			break
+		}
+		n += utf16Len(r)
+	}
+	return n
+}
+
+// ByteOffset returns the byte offset of the UTF-16 offset char in line, the
+// inverse of Character.
+func ByteOffset(line string, char int) int {
+	n := 0
+	for i, r := range line {
+		if n >= char {
+			return i
+		}
+		n += utf16Len(r)
+	}
+	return len(line)
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
@@ -2,22 +2,59 @@ package scanner
 
 import (
 	"bufio"
+	"context"
 	"os"
 	"strings"
+	"sync"
 )
 
+// Scanner reads user input from stdin. A single goroutine reads the lines,
+// so a read given up when its context is done leaves no read pending and
+// the line goes to the next caller instead.
 type Scanner struct {
-	scn *bufio.Scanner
+	r *reader
+}
+
+type reader struct {
+	once  sync.Once
+	lines chan string
 }
 
 func New() Scanner {
-	return Scanner{scn: bufio.NewScanner(os.Stdin)}
+	return Scanner{r: &reader{lines: make(chan string)}}
 }
 
-func (s Scanner) GetUserMessage() (string, bool) {
+// readLine waits for the next line until ctx is done. It returns false at
+// end of input or when ctx is done.
+func (s Scanner) readLine(ctx context.Context) (string, bool) {
+	// Start reading on first use so stdin is left alone when no input is
+	// asked for.
+	s.r.once.Do(func() {
+		go func() {
+			scn := bufio.NewScanner(os.Stdin)
+			for scn.Scan() {
+				s.r.lines <- scn.Text()
+			}
+			close(s.r.lines)
+		}()
+	})
+	select {
+	case line, ok := <-s.r.lines:
+		return line, ok
+	case <-ctx.Done():
+		return "", false
+	}
+}
+
+// GetUserMessage reads lines up to the first empty one. It returns false
+// when the message is empty, at end of input or when ctx is done.
+func (s Scanner) GetUserMessage(ctx context.Context) (string, bool) {
 	var message string
-	for s.scn.Scan() {
-		line := s.scn.Text()
+	for {
+		line, ok := s.readLine(ctx)
+		if !ok {
+			return message, false
+		}
 		if line == "" {
 			if strings.TrimSpace(message) == "" {
 				return "", false
@@ -26,5 +63,10 @@ func (s Scanner) GetUserMessage() (string, bool) {
 		}
 		message += line + "\n"
 	}
-	return message, false
+}
+
+// GetLine reads a single line of input. It returns false at end of input or
+// when ctx is done.
+func (s Scanner) GetLine(ctx context.Context) (string, bool) {
+	return s.readLine(ctx)
 }
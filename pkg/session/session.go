@@ -0,0 +1,223 @@
+// Package session persists agent conversations so they can be resumed later.
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/genai"
+)
+
+// ErrNotFound is returned when a session does not exist in the store.
+var ErrNotFound = errors.New("session not found")
+
+// Session is a saved conversation.
+type Session struct {
+	ID        string           `json:"id"`
+	Workspace string           `json:"workspace"`
+	Provider  string           `json:"provider"`
+	Model     string           `json:"model"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	History   []*genai.Content `json:"history"`
+}
+
+// Title returns the first user message, shortened to a single line.
+func (s *Session) Title() string {
+	for _, content := range s.History {
+		if content.Role != genai.RoleUser {
+			continue
+		}
+		for _, part := range content.Parts {
+			if text := strings.TrimSpace(part.Text); text != "" {
+				line, _, _ := strings.Cut(text, "\n")
+				if len(line) > 60 {
+					cut := 57
+					for cut > 0 && !utf8.RuneStart(line[cut]) {
+						cut--
+					}
+					line = line[:cut] + "..."
+				}
+				return line
+			}
+		}
+	}
+	return ""
+}
+
+// Store keeps the sessions of one workspace as JSON files in a directory.
+type Store struct {
+	Dir       string
+	Workspace string
+}
+
+// NewStore returns the store for the given workspace directory. Sessions are
+// kept under the user config directory, in a folder derived from the
+// workspace's absolute path.
+func NewStore(workspace string) (*Store, error) {
+	abs, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace %s: %w", workspace, err)
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate user config directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(abs))
+	name := filepath.Base(abs) + "-" + hex.EncodeToString(sum[:])[:12]
+	return &Store{
+		Dir:       filepath.Join(base, "cooder-assist", "sessions", name),
+		Workspace: abs,
+	}, nil
+}
+
+// New creates an empty session. It is only written to disk by Save.
+func (s *Store) New(provider, model string) *Session {
+	now := time.Now()
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return &Session{
+		ID:        now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix),
+		Workspace: s.Workspace,
+		Provider:  provider,
+		Model:     model,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Save writes the session atomically, replacing any previous version.
+func (s *Store) Save(sess *Session) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create session directory %s: %w", s.Dir, err)
+	}
+	sess.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session %s: %w", sess.ID, err)
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, sess.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save session %s: %w", sess.ID, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save session %s: %w", sess.ID, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save session %s: %w", sess.ID, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(sess.ID)); err != nil {
+		return fmt.Errorf("failed to save session %s: %w", sess.ID, err)
+	}
+	return nil
+}
+
+// Load reads the session with the given ID.
+func (s *Store) Load(id string) (*Session, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid session id '%s'", id)
+	}
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", id, err)
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", id, err)
+	}
+	return &sess, nil
+}
+
+// List returns every saved session, most recently updated first. Session
+// files that cannot be read or decoded are left out and their errors
+// returned in skipped.
+func (s *Store) List() (sessions []*Session, skipped []error, err error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		sess, err := s.Load(id)
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+	slices.SortFunc(sessions, func(a, b *Session) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	return sessions, skipped, nil
+}
+
+// Latest returns the most recently updated session that can be read.
+func (s *Store) Latest() (*Session, error) {
+	sessions, _, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("%w: no saved sessions for %s", ErrNotFound, s.Workspace)
+	}
+	return sessions[0], nil
+}
+
+// Delete removes the session with the given ID.
+func (s *Store) Delete(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid session id '%s'", id)
+	}
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", id, err)
+	}
+	if err := os.RemoveAll(s.CheckpointDir(id)); err != nil {
+		return fmt.Errorf("failed to delete checkpoints of session %s: %w", id, err)
+	}
+	return nil
+}
+
+// CheckpointDir is the directory holding the file checkpoints of a session.
+func (s *Store) CheckpointDir(id string) string {
+	return filepath.Join(s.Dir, id+".checkpoints")
+}
+
+// WorktreeDir is the directory of the git worktree a session works in when
+// worktree mode is on.
+func (s *Store) WorktreeDir(id string) string {
+	return filepath.Join(s.Dir, id+".worktree")
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
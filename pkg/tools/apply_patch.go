@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const applyPatchDescription = `Apply a unified diff to one or more files in the workspace, like 'git apply'. ` +
+	`Use this for changes at several places or in several files at once. Paths in the '--- a/path' and '+++ b/path' headers are relative to the workspace root; ` +
+	`use '--- /dev/null' to create a file, '+++ /dev/null' to delete one, and different old and new paths (or git 'rename from'/'rename to' headers) to rename one. ` +
+	`Include about 3 lines of context around every change. Hunks may be slightly off in line numbers or whitespace, but if any hunk does not match, nothing is changed ` +
+	`and the error shows the actual text near the hunk. The user is shown the resulting changes and must approve them before they are applied.`
+
+type applyPatchArgs struct {
+	Patch string `json:"patch"`
+}
+
+var applyPatchTool = Func[applyPatchArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: applyPatchDescription,
+		Name:        "apply_patch",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"patch": {
+					Type:        genai.TypeString,
+					Description: "The unified diff to apply",
+				},
+			},
+			Required: []string{"patch"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args applyPatchArgs) (string, error) {
+		plan, err := planPatch(env, args.Patch)
+		if err != nil {
+			return "", err
+		}
+		paths := make([]string, len(plan.changes))
+		for i, change := range plan.changes {
+			paths[i] = change.Path
+		}
+		if err := env.checkpoint("apply_patch", paths, func() error { return ApplyChanges(plan.changes) }); err != nil {
+			return "", err
+		}
+		return "Patch applied:\n" + strings.Join(append(plan.summary, plan.notes...), "\n") + env.editDiagnostics(ctx, paths), nil
+	},
+	PreviewRun: func(ctx context.Context, env *Env, args applyPatchArgs) (*ApprovalRequest, error) {
+		plan, err := planPatch(env, args.Patch)
+		if err != nil {
+			return nil, err
+		}
+		return &ApprovalRequest{
+			Summary: "Apply patch: " + strings.Join(plan.summary, ", "),
+			Preview: strings.Join(plan.diffs, ""),
+		}, nil
+	},
+}
+
+func init() {
+	builtins = append(builtins, applyPatchTool)
+}
+
+// patchPlan holds the file changes a patch makes, computed before anything is
+// written.
+type patchPlan struct {
+	changes []FileChange
+	// summary has one line per file, e.g. "M pkg/tools/tools.go".
+	summary []string
+	// notes report hunks applied with an offset or fuzz.
+	notes []string
+	// diffs show the change to every file as it will be written.
+	diffs []string
+}
+
+// planPatch parses the patch and applies it in memory. Every file is checked
+// so the error lists all hunks that do not match.
+func planPatch(env *Env, patch string) (*patchPlan, error) {
+	files, err := ParsePatch(patch)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	plan := &patchPlan{}
+	var errs []error
+	for _, file := range files {
+		if err := plan.add(env, file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("patch was not applied, no files were changed:\n%w", errors.Join(errs...))
+	}
+	return plan, nil
+}
+
+func (p *patchPlan) add(env *Env, file *FilePatch) error {
+	var oldPath, newPath, content string
+	var err error
+	if file.OldPath != "" {
+		if oldPath, err = env.Workspace.Resolve(file.OldPath, true); err != nil {
+			return err
+		}
+		if content, err = ReadFile(oldPath); err != nil {
+			return err
+		}
+	}
+	if file.NewPath != "" {
+		if newPath, err = env.Workspace.Resolve(file.NewPath, true); err != nil {
+			return err
+		}
+		if newPath != oldPath {
+			if _, err := os.Stat(newPath); !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("cannot %s '%s': the file already exists", file.Kind(), file.NewPath)
+			}
+		}
+	}
+
+	result, hunks, err := ApplyHunks(file.name(), content, file.Hunks)
+	if err != nil {
+		return err
+	}
+	for _, hunk := range hunks {
+		note := fmt.Sprintf("hunk %d of %s applied at line %d", hunk.Index, file.name(), hunk.Line)
+		var details []string
+		if hunk.Offset != 0 {
+			details = append(details, fmt.Sprintf("offset %+d lines", hunk.Offset))
+		}
+		if hunk.Fuzz != "" {
+			details = append(details, hunk.Fuzz)
+		}
+		if len(details) > 0 {
+			note += " (" + strings.Join(details, ", ") + ")"
+		}
+		p.notes = append(p.notes, note)
+	}
+
+	oldName, newName, newContent := "a/"+file.OldPath, "b/"+file.NewPath, result
+	if file.OldPath == "" {
+		oldName = devNull
+	}
+	if file.NewPath == "" {
+		newName, newContent = devNull, ""
+	}
+	diff := UnifiedDiff(content, newContent, DiffOptions{OldName: oldName, NewName: newName, Context: DefaultDiffContext})
+	if diff == "" {
+		diff = fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName)
+	}
+	p.diffs = append(p.diffs, diff)
+
+	switch file.Kind() {
+	case "create":
+		p.changes = append(p.changes, FileChange{Path: newPath, Content: []byte(result)})
+		p.summary = append(p.summary, "A "+file.NewPath)
+	case "delete":
+		p.changes = append(p.changes, FileChange{Path: oldPath})
+		p.summary = append(p.summary, "D "+file.OldPath)
+	case "rename":
+		p.changes = append(p.changes, FileChange{Path: newPath, Content: []byte(result)}, FileChange{Path: oldPath})
+		p.summary = append(p.summary, fmt.Sprintf("R %s -> %s", file.OldPath, file.NewPath))
+	default:
+		p.changes = append(p.changes, FileChange{Path: newPath, Content: []byte(result)})
+		p.summary = append(p.summary, "M "+file.NewPath)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+// Decision is the answer of an Approver.
+type Decision int
+
+const (
+	Reject        Decision = iota // do not run the call
+	Approve                       // run this call
+	ApproveAlways                 // run this call and every later call of the same tool and scope
+)
+
+// ApprovalRequest describes a mutating tool call awaiting approval.
+type ApprovalRequest struct {
+	Tool    string // name of the tool, e.g. "edit_file"
+	Summary string // one line description of the change
+	Preview string // exact diff or commit summary that will be applied
+	// Scope, when set, limits an "always" answer to later calls with the
+	// same scope, e.g. "go test" for run_command. Otherwise it covers every
+	// later call of the tool.
+	Scope string
+}
+
+// Approval is the answer to an ApprovalRequest. Feedback is an optional
+// explanation returned to the model when the call is rejected.
+type Approval struct {
+	Decision Decision
+	Feedback string
+}
+
+// Approver is asked before a mutating tool is executed. It rejects the call
+// when ctx is done before it has an answer.
+type Approver interface {
+	Approve(ctx context.Context, req ApprovalRequest) Approval
+}
+
+// ApproverFunc adapts a function to the Approver interface.
+type ApproverFunc func(ctx context.Context, req ApprovalRequest) Approval
+
+func (f ApproverFunc) Approve(ctx context.Context, req ApprovalRequest) Approval {
+	return f(ctx, req)
+}
+
+// ApproveAll approves every call without asking.
+var ApproveAll = ApproverFunc(func(context.Context, ApprovalRequest) Approval {
+	return Approval{Decision: Approve}
+})
+
+// RejectAll returns an Approver rejecting every call with the given feedback.
+func RejectAll(feedback string) Approver {
+	return ApproverFunc(func(context.Context, ApprovalRequest) Approval {
+		return Approval{Decision: Reject, Feedback: feedback}
+	})
+}
+
+// approve asks the approver about the call when the tool implements
+// Previewer, remembering "always" answers. It returns nil when the call may
+// proceed, otherwise the function response reporting the rejection.
+func (t *Tools) approve(ctx context.Context, tool Tool, args map[string]any) map[string]any {
+	previewer, ok := tool.(Previewer)
+	if !ok || t.Approver == nil || t.alwaysApproved[tool.Name()] {
+		return nil
+	}
+
+	req, err := previewer.Preview(ctx, &t.Env, args)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	if req == nil {
+		return nil
+	}
+	if req.Tool == "" {
+		req.Tool = tool.Name()
+	}
+	key := tool.Name()
+	if req.Scope != "" {
+		key += " " + req.Scope
+		if t.alwaysApproved[key] {
+			return nil
+		}
+	}
+
+	approval := t.Approver.Approve(ctx, *req)
+	switch approval.Decision {
+	case Approve:
+		return nil
+	case ApproveAlways:
+		if t.alwaysApproved == nil {
+			t.alwaysApproved = make(map[string]bool)
+		}
+		t.alwaysApproved[key] = true
+		return nil
+	}
+
+	response := map[string]any{
+		"error":    fmt.Sprintf("the user rejected the '%s' call, nothing was changed", tool.Name()),
+		"rejected": true,
+	}
+	if approval.Feedback != "" {
+		response["feedback"] = approval.Feedback
+	}
+	return response
+}
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileChange is the new state of one file. A nil Content deletes the file.
+type FileChange struct {
+	Path    string
+	Content []byte
+}
+
+// fileBackup is the state of a file before ApplyChanges touched it.
+type fileBackup struct {
+	path    string
+	content []byte
+	mode    fs.FileMode
+	existed bool
+}
+
+// ApplyChanges writes every change or none of them. New contents are first
+// written to temporary files next to their targets, then renamed into place;
+// if a rename or delete fails, the files already changed are restored.
+// Existing files keep their permissions and missing directories are created.
+func ApplyChanges(changes []FileChange) error {
+	seen := make(map[string]bool)
+	backups := make([]fileBackup, 0, len(changes))
+	for _, change := range changes {
+		if seen[change.Path] {
+			return fmt.Errorf("file '%s' is changed more than once", change.Path)
+		}
+		seen[change.Path] = true
+
+		backup := fileBackup{path: change.Path, mode: 0644}
+		info, err := os.Stat(change.Path)
+		switch {
+		case err == nil && info.IsDir():
+			return fmt.Errorf("'%s' is a directory", change.Path)
+		case err == nil:
+			if backup.content, err = os.ReadFile(change.Path); err != nil {
+				return fmt.Errorf("failed to read '%s': %w", change.Path, err)
+			}
+			backup.mode = info.Mode().Perm()
+			backup.existed = true
+		case !errors.Is(err, fs.ErrNotExist):
+			return fmt.Errorf("failed to check '%s': %w", change.Path, err)
+		case change.Content == nil:
+			return fmt.Errorf("cannot delete '%s': %w", change.Path, fs.ErrNotExist)
+		}
+		backups = append(backups, backup)
+	}
+
+	// Stage every new content so that a full disk or a permission problem is
+	// found before any file is replaced.
+	temps := make([]string, len(changes))
+	defer func() {
+		for _, temp := range temps {
+			if temp != "" {
+				os.Remove(temp)
+			}
+		}
+	}()
+	for i, change := range changes {
+		if change.Content == nil {
+			continue
+		}
+		temp, err := stageFile(change.Path, change.Content, backups[i].mode)
+		if err != nil {
+			return err
+		}
+		temps[i] = temp
+	}
+
+	for i, change := range changes {
+		var err error
+		if change.Content == nil {
+			err = os.Remove(change.Path)
+		} else if err = os.Rename(temps[i], change.Path); err == nil {
+			temps[i] = ""
+		}
+		if err != nil {
+			restoreFiles(backups[:i])
+			return fmt.Errorf("failed to write '%s', no files were changed: %w", change.Path, err)
+		}
+	}
+	return nil
+}
+
+// stageFile writes content to a temporary file in the directory of path.
+func stageFile(path string, content []byte, mode fs.FileMode) (string, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to write '%s': %w", path, err)
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write '%s': %w", path, err)
+	}
+	return f.Name(), nil
+}
+
+// restoreFiles puts back the files changed before a failure.
+func restoreFiles(backups []fileBackup) {
+	for _, backup := range backups {
+		if !backup.existed {
+			os.Remove(backup.path)
+			continue
+		}
+		os.WriteFile(backup.path, backup.content, backup.mode)
+	}
+}
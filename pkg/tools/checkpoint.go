@@ -0,0 +1,279 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Checkpoint holds the files a tool call changed as they were before it ran.
+type Checkpoint struct {
+	ID    int         `json:"id"`
+	Tool  string      `json:"tool"`
+	Time  time.Time   `json:"time"`
+	Files []FileImage `json:"files"`
+}
+
+// FileImage is the state of one file at a checkpoint.
+type FileImage struct {
+	Path string `json:"path"`
+	// Existed is false when the tool call created the file; restoring the
+	// image then deletes it.
+	Existed bool        `json:"existed"`
+	Content []byte      `json:"content,omitempty"`
+	Mode    fs.FileMode `json:"mode,omitempty"`
+}
+
+// Checkpoints stores the before-images of the files changed by the mutating
+// tools, one JSON file per tool call, so changes can be undone without git
+// and after a restart. It is not safe for concurrent use.
+type Checkpoints struct {
+	Dir string
+}
+
+// OpenCheckpoints returns the checkpoint store kept in dir. The directory is
+// created when the first checkpoint is recorded.
+func OpenCheckpoints(dir string) *Checkpoints {
+	return &Checkpoints{Dir: dir}
+}
+
+// Record saves the current state of the files at paths as a new checkpoint.
+func (c *Checkpoints) Record(tool string, paths []string) (*Checkpoint, error) {
+	// The next ID comes from the file names, so recording does not decode
+	// every earlier checkpoint.
+	ids, err := c.ids()
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{ID: 1, Tool: tool, Time: time.Now()}
+	if len(ids) > 0 {
+		cp.ID = ids[len(ids)-1] + 1
+	}
+	for _, path := range paths {
+		image := FileImage{Path: path}
+		info, err := os.Stat(path)
+		switch {
+		case err == nil:
+			if image.Content, err = os.ReadFile(path); err != nil {
+				return nil, fmt.Errorf("failed to checkpoint '%s': %w", path, err)
+			}
+			image.Existed, image.Mode = true, info.Mode().Perm()
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, fmt.Errorf("failed to checkpoint '%s': %w", path, err)
+		}
+		cp.Files = append(cp.Files, image)
+	}
+
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory %s: %w", c.Dir, err)
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode checkpoint %d: %w", cp.ID, err)
+	}
+	temp, err := stageFile(c.path(cp.ID), data, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(temp, c.path(cp.ID)); err != nil {
+		os.Remove(temp)
+		return nil, fmt.Errorf("failed to save checkpoint %d: %w", cp.ID, err)
+	}
+	return cp, nil
+}
+
+// Discard deletes a checkpoint without restoring it.
+func (c *Checkpoints) Discard(id int) error {
+	if err := os.Remove(c.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete checkpoint %d: %w", id, err)
+	}
+	return nil
+}
+
+// List returns every checkpoint, oldest first.
+func (c *Checkpoints) List() ([]*Checkpoint, error) {
+	ids, err := c.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Checkpoint
+	for _, id := range ids {
+		data, err := os.ReadFile(c.path(id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint %d: %w", id, err)
+		}
+		var cp Checkpoint
+		if err := json.Unmarshal(data, &cp); err != nil {
+			return nil, fmt.Errorf("failed to decode checkpoint %d: %w", id, err)
+		}
+		list = append(list, &cp)
+	}
+	slices.SortFunc(list, func(a, b *Checkpoint) int { return a.ID - b.ID })
+	return list, nil
+}
+
+// ids returns the IDs of the stored checkpoints, read from their file names,
+// in ascending order.
+func (c *Checkpoints) ids() ([]int, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	var ids []int
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if id, err := strconv.Atoi(name); err == nil && c.path(id) == filepath.Join(c.Dir, entry.Name()) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// Modified returns the files changed by the checkpointed tool calls, in the
+// order they were first changed. Undone calls no longer count.
+func (c *Checkpoints) Modified() ([]string, error) {
+	list, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, cp := range list {
+		for _, image := range cp.Files {
+			if !seen[image.Path] {
+				seen[image.Path] = true
+				paths = append(paths, image.Path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// Undo restores the files changed by the last n tool calls and deletes their
+// checkpoints. It returns the checkpoints undone, newest first.
+func (c *Checkpoints) Undo(n int) ([]*Checkpoint, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of changes to undo: %d", n)
+	}
+	list, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.New("there are no changes to undo")
+	}
+	if n > len(list) {
+		return nil, fmt.Errorf("only %d changes can be undone", len(list))
+	}
+	return c.rollback(list[len(list)-n:])
+}
+
+// Restore puts the files back in the state they were in before checkpoint id
+// was recorded, undoing that tool call and every later one.
+func (c *Checkpoints) Restore(id int) ([]*Checkpoint, error) {
+	list, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(list, func(cp *Checkpoint) bool { return cp.ID == id })
+	if i < 0 {
+		return nil, fmt.Errorf("checkpoint %d does not exist", id)
+	}
+	return c.rollback(list[i:])
+}
+
+// rollback restores the oldest image of every file in the checkpoints, which
+// are ordered oldest first, and deletes them.
+func (c *Checkpoints) rollback(list []*Checkpoint) ([]*Checkpoint, error) {
+	images := make(map[string]FileImage)
+	var order []string
+	for _, cp := range list {
+		for _, image := range cp.Files {
+			if _, seen := images[image.Path]; !seen {
+				images[image.Path] = image
+				order = append(order, image.Path)
+			}
+		}
+	}
+
+	var changes []FileChange
+	var modes []fs.FileMode
+	for _, path := range order {
+		image := images[path]
+		if !image.Existed {
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			changes = append(changes, FileChange{Path: path})
+			modes = append(modes, 0)
+			continue
+		}
+		content := image.Content
+		if content == nil {
+			content = []byte{}
+		}
+		changes = append(changes, FileChange{Path: path, Content: content})
+		modes = append(modes, image.Mode)
+	}
+	if err := ApplyChanges(changes); err != nil {
+		return nil, err
+	}
+	// Files deleted since the checkpoint are recreated with 0644; put back
+	// their recorded permissions.
+	for i, change := range changes {
+		if modes[i] != 0 {
+			os.Chmod(change.Path, modes[i])
+		}
+	}
+
+	var errs []error
+	for _, cp := range list {
+		if err := c.Discard(cp.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	slices.Reverse(list)
+	return list, errors.Join(errs...)
+}
+
+func (c *Checkpoints) path(id int) string {
+	return filepath.Join(c.Dir, strconv.Itoa(id)+".json")
+}
+
+// checkpoint records the files at paths before write changes them. The
+// checkpoint is dropped again when write fails, as nothing was changed.
+func (env *Env) checkpoint(tool string, paths []string, write func() error) error {
+	if env.Checkpoints == nil {
+		if err := write(); err != nil {
+			return err
+		}
+		env.syncLanguageServer(paths)
+		return nil
+	}
+	cp, err := env.Checkpoints.Record(tool, paths)
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		env.Checkpoints.Discard(cp.ID)
+		return err
+	}
+	env.syncLanguageServer(paths)
+	return nil
+}
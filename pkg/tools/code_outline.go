@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+
+	"google.golang.org/genai"
+)
+
+const codeOutlineDescription = `Outline a Go source file, or the Go package in a directory, without reading it whole: ` +
+	`the package name, imports and every top-level type, function, method, constant and variable with its signature, line range and the first paragraph of its doc comment. ` +
+	`Use read_symbol to get the source of a declaration, or read_file with the line range. Only Go files are supported.`
+
+type codeOutlineArgs struct {
+	Path         string `json:"path"`
+	IncludeTests bool   `json:"include_tests"`
+}
+
+// CodeOutline is the output of code_outline.
+type CodeOutline struct {
+	// Packages are the package names declared; a directory may hold both a
+	// package and its external test package.
+	Packages []string    `json:"packages"`
+	Files    []string    `json:"files"`
+	Imports  []string    `json:"imports"`
+	Symbols  []*GoSymbol `json:"symbols"`
+}
+
+var codeOutlineTool = Func[codeOutlineArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: codeOutlineDescription,
+		Name:        "code_outline",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"path": {
+					Type:        genai.TypeString,
+					Description: "A .go file, or a directory to outline the package in it",
+				},
+				"include_tests": {
+					Type:        genai.TypeBoolean,
+					Description: "Also outline the _test.go files of a directory (default: false)",
+				},
+			},
+			Required: []string{"path"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args codeOutlineArgs) (string, error) {
+		path, err := env.Workspace.Resolve(args.Path, false)
+		if err != nil {
+			return "", err
+		}
+		outline, err := OutlineGo(path, args.Path, args.IncludeTests)
+		if err != nil {
+			return "", err
+		}
+		return marshalOutput(outline)
+	},
+}
+
+func init() {
+	builtins = append(builtins, codeOutlineTool)
+}
+
+// OutlineGo outlines the Go file at path, or the package in the directory at
+// path. name is how path is shown in the outline.
+func OutlineGo(path, name string, tests bool) (*CodeOutline, error) {
+	files, err := parseGoFiles(path, name, tests)
+	if err != nil {
+		return nil, err
+	}
+	outline := &CodeOutline{Symbols: []*GoSymbol{}}
+	for _, f := range files {
+		outline.Packages = append(outline.Packages, f.ast.Name.Name)
+		outline.Files = append(outline.Files, f.name)
+		outline.Imports = append(outline.Imports, f.imports()...)
+		outline.Symbols = append(outline.Symbols, f.symbols()...)
+	}
+	outline.Packages = sortedUnique(outline.Packages)
+	outline.Imports = sortedUnique(outline.Imports)
+	if outline.Imports == nil {
+		outline.Imports = []string{}
+	}
+	return outline, nil
+}
@@ -2,6 +2,7 @@
 package tools
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -12,33 +13,68 @@ import (
 
 const createFileDescription = `Create a new file at the given 'path' with the specified 'content'. ` + // Description of the create file tool for the Gemini API
 	`If the file already exists, it will be overwritten unless 'overwrite' is set to false. ` +
-	`Directory structure will be created automatically if it doesn't exist.`
+	`Directory structure will be created automatically if it doesn't exist. The user must approve the new content before the file is written.`
 
-var createFileTool = &genai.Tool{ // Definition of the create file tool
-	FunctionDeclarations: []*genai.FunctionDeclaration{
-		{
-			Description: createFileDescription,
-			Name:        "create_file",
-			Parameters: &genai.Schema{
-				Type: genai.TypeObject,
-				Properties: map[string]*genai.Schema{
-					"path": {
-						Type:        genai.TypeString,
-						Description: "The file path where the new file should be created",
-					},
-					"content": {
-						Type:        genai.TypeString,
-						Description: "The content to write to the new file",
-					},
-					"overwrite": {
-						Type:        genai.TypeBoolean,
-						Description: "Whether to overwrite the file if it already exists (default: true)",
-					},
+type createFileArgs struct {
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Overwrite *bool  `json:"overwrite"`
+}
+
+var createFileTool = Func[createFileArgs]{ // Definition of the create file tool
+	Decl: &genai.FunctionDeclaration{
+		Description: createFileDescription,
+		Name:        "create_file",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"path": {
+					Type:        genai.TypeString,
+					Description: "The file path where the new file should be created",
+				},
+				"content": {
+					Type:        genai.TypeString,
+					Description: "The content to write to the new file",
+				},
+				"overwrite": {
+					Type:        genai.TypeBoolean,
+					Description: "Whether to overwrite the file if it already exists (default: true)",
 				},
-				Required: []string{"path", "content"},
 			},
+			Required: []string{"path", "content"},
 		},
 	},
+	Run: func(ctx context.Context, env *Env, args createFileArgs) (string, error) {
+		path, err := env.Workspace.Resolve(args.Path, true)
+		if err != nil {
+			return "", err
+		}
+		err = env.checkpoint("create_file", []string{path}, func() error {
+			return CreateFileWithDefaults(path, args.Content, args.Overwrite)
+		})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("File '%s' created successfully", args.Path) + env.editDiagnostics(ctx, []string{path}), nil
+	},
+	PreviewRun: func(ctx context.Context, env *Env, args createFileArgs) (*ApprovalRequest, error) {
+		path, err := env.Workspace.Resolve(args.Path, true)
+		if err != nil {
+			return nil, err
+		}
+		oldContent, err := ReadFile(path)
+		summary := fmt.Sprintf("Overwrite %s", args.Path)
+		created := err != nil
+		if created {
+			oldContent = ""
+			summary = fmt.Sprintf("Create %s", args.Path)
+		}
+		return &ApprovalRequest{Summary: summary, Preview: FileDiff(args.Path, oldContent, args.Content, created)}, nil
+	},
+}
+
+func init() {
+	builtins = append(builtins, createFileTool)
 }
 
 func CreateFile(filePath, content string, overwrite bool) error { // CreateFile creates a new file with the given content and overwrite option.
@@ -69,9 +105,9 @@ func CreateFile(filePath, content string, overwrite bool) error { // CreateFile
 		}
 	}
 
-	// Create/write the file
-	err := os.WriteFile(filePath, []byte(content), 0644)
-	if err != nil {
+	// Write through a temporary file renamed into place, so a symlink at
+	// filePath is replaced rather than followed out of the workspace
+	if err := ApplyChanges([]FileChange{{Path: filePath, Content: []byte(content)}}); err != nil {
 		return fmt.Errorf("failed to create file %s: %w", filePath, err) // Return error if failed to create file
 	}
 
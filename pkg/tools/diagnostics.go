@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/genai"
+)
+
+const diagnosticsDescription = `Ask the language server (gopls for Go) for the compile errors, vet findings and other diagnostics of files, e.g. to check that an edit compiles. ` +
+	`By default the files you changed in this session are checked; pass 'paths' to choose files, or directories to check the files directly in them. ` +
+	`Returns every diagnostic of those files and the errors reported in other files, which the changes may have caused.`
+
+type diagnosticsArgs struct {
+	Paths []string `json:"paths"`
+}
+
+// Diagnostics is the output of the diagnostics tool.
+type Diagnostics struct {
+	Diagnostics []*FileDiagnostic `json:"diagnostics"`
+	// Pending are files the language server had not finished checking in
+	// time; their diagnostics may be stale.
+	Pending   []string `json:"pending,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+var diagnosticsTool = Func[diagnosticsArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: diagnosticsDescription,
+		Name:        "diagnostics",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"paths": {
+					Type:        genai.TypeArray,
+					Items:       &genai.Schema{Type: genai.TypeString},
+					Description: "Files or directories to check (default: the files changed in this session)",
+				},
+			},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args diagnosticsArgs) (string, error) {
+		if env.LSP == nil {
+			return "", errors.New("no language server is configured")
+		}
+		paths, err := env.diagnosticsPaths(args.Paths)
+		if err != nil {
+			return "", err
+		}
+		diags, pending, err := env.diagnostics(ctx, paths)
+		if err != nil {
+			return "", err
+		}
+		result := Diagnostics{Diagnostics: diags, Pending: pending}
+		if result.Diagnostics == nil {
+			result.Diagnostics = []*FileDiagnostic{}
+		}
+		if len(result.Diagnostics) > maxDiagnostics {
+			result.Diagnostics, result.Truncated = result.Diagnostics[:maxDiagnostics], true
+		}
+		return marshalOutput(result)
+	},
+}
+
+func init() {
+	builtins = append(builtins, diagnosticsTool)
+}
+
+// diagnosticsPaths resolves the files to check: the given files and the
+// files the language server handles in the given directories, or the files
+// changed in the session.
+func (env *Env) diagnosticsPaths(args []string) ([]string, error) {
+	if len(args) == 0 {
+		if env.Checkpoints == nil {
+			return nil, errors.New("no paths given and changes are not tracked in this session")
+		}
+		modified, err := env.Checkpoints.Modified()
+		if err != nil {
+			return nil, err
+		}
+		var paths []string
+		for _, path := range modified {
+			if _, err := os.Stat(path); err == nil && env.LSP.Handles(path) {
+				paths = append(paths, path)
+			}
+		}
+		if len(paths) == 0 {
+			return nil, errors.New("no paths given and no files the language server handles were changed in this session")
+		}
+		return paths, nil
+	}
+
+	var paths []string
+	for _, arg := range args {
+		path, err := env.Workspace.Resolve(arg, false)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if _, err := env.languageServer(path); err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, entry := range entries {
+			if !entry.IsDir() && env.LSP.Handles(entry.Name()) {
+				paths = append(paths, filepath.Join(path, entry.Name()))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("'%s' has no files the language server handles", arg)
+		}
+	}
+	return paths, nil
+}
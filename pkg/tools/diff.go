@@ -1,63 +1,331 @@
-// This file implements the git diff functionality.
-// It allows displaying the git diff of a specified file.
+// This file implements the diff tool and the unified diffs shown when
+// changes are reviewed.
 package tools
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"os/exec"
+	"strings"
+	"unicode"
 
 	"google.golang.org/genai"
 )
 
-const diffDescription = `Display the diff between two strings . This tool should be executed to review the changes before using the edit_file tool.`
-
-var diffTool = &genai.Tool{
-	FunctionDeclarations: []*genai.FunctionDeclaration{
-		{
-			Description: diffDescription,
-			Name:        "diff",
-			Parameters: &genai.Schema{
-				Type: genai.TypeObject,
-				Properties: map[string]*genai.Schema{
-					"old_string": {
-						Type: genai.TypeString,
-					},
-					"new_string": {
-						Type: genai.TypeString,
-					}},
-			},
+const diffDescription = `Display the unified diff between two strings. With 'path' set, preview an edit instead: the diff shows the file as it would look ` +
+	`after edit_file replaced 'old_string' with 'new_string' (honouring 'replace_all' and 'occurrence'), without writing it. ` +
+	`This tool should be executed to review the changes before using the edit_file tool.`
+
+// DefaultDiffContext is the number of unchanged lines shown around changes.
+const DefaultDiffContext = 3
+
+type diffArgs struct {
+	Edit
+	Path         string `json:"path"`
+	ContextLines *int   `json:"context_lines"`
+}
+
+var diffTool = Func[diffArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: diffDescription,
+		Name:        "diff",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: func() map[string]*genai.Schema {
+				props := editProperties()
+				props["path"] = &genai.Schema{
+					Type:        genai.TypeString,
+					Description: "File to preview the edit of; when omitted, old_string and new_string are diffed directly",
+				}
+				props["context_lines"] = &genai.Schema{
+					Type:        genai.TypeInteger,
+					Description: fmt.Sprintf("Number of unchanged lines to show around each change (default: %d)", DefaultDiffContext),
+					Minimum:     genai.Ptr(0.0),
+				}
+				return props
+			}(),
+			Required: []string{"old_string", "new_string"},
 		},
 	},
+	Run: func(ctx context.Context, env *Env, args diffArgs) (string, error) {
+		opts := DiffOptions{OldName: "old", NewName: "new", Context: DefaultDiffContext}
+		if args.ContextLines != nil {
+			opts.Context = *args.ContextLines
+		}
+		oldStr, newStr := args.OldString, args.NewString
+		if args.Path != "" {
+			path, err := env.Workspace.Resolve(args.Path, true)
+			if err != nil {
+				return "", err
+			}
+			if oldStr, newStr, err = editedContent(path, []Edit{args.Edit}); err != nil {
+				return "", err
+			}
+			opts.OldName, opts.NewName = "a/"+args.Path, "b/"+args.Path
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+				opts.OldName = devNull
+			}
+		}
+		if diff := UnifiedDiff(oldStr, newStr, opts); diff != "" {
+			return diff, nil
+		}
+		return "No differences.", nil
+	},
+}
+
+func init() {
+	builtins = append(builtins, diffTool)
+}
+
+// DiffOptions controls UnifiedDiff.
+type DiffOptions struct {
+	// OldName and NewName label the "---" and "+++" headers.
+	OldName string
+	NewName string
+	// Context is the number of unchanged lines shown around each change.
+	Context int
 }
 
+// Diff returns the unified diff between two strings with the default context.
 func Diff(oldStr, newStr string) (string, error) {
+	return UnifiedDiff(oldStr, newStr, DiffOptions{OldName: "old", NewName: "new", Context: DefaultDiffContext}), nil
+}
+
+// FileDiff returns the unified diff of a change to the file at path, labelled
+// like git with "a/" and "b/" prefixes, or /dev/null for a created file.
+func FileDiff(path, oldContent, newContent string, created bool) string {
+	oldName := "a/" + path
+	if created {
+		oldName = devNull
+	}
+	return UnifiedDiff(oldContent, newContent, DiffOptions{OldName: oldName, NewName: "b/" + path, Context: DefaultDiffContext})
+}
+
+// UnifiedDiff returns the differences between two texts in unified format,
+// or "" when they are equal. A missing final newline is marked with
+// "\ No newline at end of file" as diff -u does.
+func UnifiedDiff(oldStr, newStr string, opts DiffOptions) string {
+	if oldStr == newStr {
+		return ""
+	}
+	context := max(opts.Context, 0)
+	ops := diffSeq(splitLines(oldStr), splitLines(newStr))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", opts.OldName, opts.NewName)
+
+	// oldLine and newLine count the lines of each side before every op.
+	oldLine := make([]int, len(ops)+1)
+	newLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if op.Op != '+' {
+			oldLine[i+1]++
+		}
+		if op.Op != '-' {
+			newLine[i+1]++
+		}
+	}
 
-	oldFile, err := os.CreateTemp("", "old-*.txt")
-	if err != nil {
-		return "", err
+	for i := 0; i < len(ops); {
+		if ops[i].Op == ' ' {
+			i++
+			continue
+		}
+		// Grow the hunk while the next change is close enough to share context.
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].Op == ' ' {
+				continue
+			}
+			if j-end-1 > 2*context {
+				break
+			}
+			end = j
+		}
+		end = min(end+context+1, len(ops))
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(oldLine[start], oldLine[end]-oldLine[start]),
+			hunkRange(newLine[start], newLine[end]-newLine[start]))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.Op)
+			if text, ok := strings.CutSuffix(op.Text, "\n"); ok {
+				out.WriteString(text)
+				out.WriteByte('\n')
+			} else {
+				out.WriteString(op.Text)
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
 	}
-	defer os.Remove(oldFile.Name())
+	return out.String()
+}
+
+// hunkRange formats one side of a hunk header from the number of lines before
+// the hunk and its length, as GNU diff does.
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, count)
+	}
+}
+
+// splitLines splits s into lines that keep their "\n".
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+const (
+	colorReset   = "\033[0m"
+	colorRed     = "\033[31m"
+	colorGreen   = "\033[32m"
+	colorCyan    = "\033[36m"
+	colorBold    = "\033[1m"
+	colorReverse = "\033[7m"
+	colorNoRev   = "\033[27m"
+)
 
-	newFile, err := os.CreateTemp("", "new-*.txt")
-	if err != nil {
-		return "", err
+// ColorizeDiff colours a unified diff for the terminal. When a block of
+// removed lines is directly followed by added lines, the changed words of each
+// pair of similar lines are highlighted.
+func ColorizeDiff(diff string) string {
+	lines := strings.Split(diff, "\n")
+	out := make([]string, len(lines))
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++"):
+			out[i] = colorBold + line + colorReset
+			i++
+		case strings.HasPrefix(line, "@@"):
+			out[i] = colorCyan + line + colorReset
+			i++
+		case strings.HasPrefix(line, "-"):
+			del := i
+			for i < len(lines) && strings.HasPrefix(lines[i], "-") && !strings.HasPrefix(lines[i], "---") {
+				i++
+			}
+			add := i
+			for i < len(lines) && strings.HasPrefix(lines[i], "+") && !strings.HasPrefix(lines[i], "+++") {
+				i++
+			}
+			for j := del; j < add; j++ {
+				out[j] = colorRed + lines[j] + colorReset
+			}
+			for j := add; j < i; j++ {
+				out[j] = colorGreen + lines[j] + colorReset
+			}
+			for j := 0; j < add-del && j < i-add; j++ {
+				if oldHL, newHL, ok := highlightWords(lines[del+j][1:], lines[add+j][1:]); ok {
+					out[del+j] = colorRed + "-" + oldHL + colorReset
+					out[add+j] = colorGreen + "+" + newHL + colorReset
+				}
+			}
+		case strings.HasPrefix(line, "+"):
+			out[i] = colorGreen + line + colorReset
+			i++
+		default:
+			out[i] = line
+			i++
+		}
 	}
-	defer os.Remove(newFile.Name())
+	return strings.Join(out, "\n")
+}
 
-	oldFile.WriteString(oldStr)
-	newFile.WriteString(newStr)
-	oldFile.Close()
-	newFile.Close()
+// highlightWords marks the words that differ between two similar lines in
+// reverse video. It reports false when the lines have too little in common
+// for the highlighting to help.
+func highlightWords(oldLine, newLine string) (string, string, bool) {
+	ops := diffSeq(wordTokens(oldLine), wordTokens(newLine))
+	same, total := 0, 0
+	for _, op := range ops {
+		if op.Op == ' ' {
+			same += 2 * len(op.Text)
+			total += 2 * len(op.Text)
+		} else {
+			total += len(op.Text)
+		}
+	}
+	if total == 0 || same*2 < total {
+		return "", "", false
+	}
 
-	cmd := exec.Command("diff", "-u", oldFile.Name(), newFile.Name())
-	output, err := cmd.CombinedOutput()
+	// Adjacent changed tokens share one highlighted span.
+	var oldOut, newOut strings.Builder
+	oldRev, newRev := false, false
+	for _, op := range ops {
+		switch op.Op {
+		case ' ':
+			if oldRev {
+				oldOut.WriteString(colorNoRev)
+			}
+			if newRev {
+				newOut.WriteString(colorNoRev)
+			}
+			oldRev, newRev = false, false
+			oldOut.WriteString(op.Text)
+			newOut.WriteString(op.Text)
+		case '-':
+			if !oldRev {
+				oldOut.WriteString(colorReverse)
+			}
+			oldRev = true
+			oldOut.WriteString(op.Text)
+		case '+':
+			if !newRev {
+				newOut.WriteString(colorReverse)
+			}
+			newRev = true
+			newOut.WriteString(op.Text)
+		}
+	}
+	if oldRev {
+		oldOut.WriteString(colorNoRev)
+	}
+	if newRev {
+		newOut.WriteString(colorNoRev)
+	}
+	return oldOut.String(), newOut.String(), true
+}
 
-	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-		return string(output), nil
+// wordTokens splits a line into words, runs of whitespace and single
+// punctuation characters.
+func wordTokens(line string) []string {
+	var tokens []string
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		default:
+			return 3
+		}
+	}
+	start, prev := 0, 0
+	for i, r := range line {
+		c := class(r)
+		if i > 0 && (c != prev || c == 3) {
+			tokens = append(tokens, line[start:i])
+			start = i
+		}
+		prev = c
 	}
-	if err != nil {
-		return "", fmt.Errorf("diff error: %w\noutput: %s", err, string(output))
+	if start < len(line) {
+		tokens = append(tokens, line[start:])
 	}
-	return string(output), nil
+	return tokens
 }
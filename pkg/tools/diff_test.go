@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// numbered returns n lines "l1\n".."ln\n" with the given lines replaced.
+func numbered(n int, replace map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if line, ok := replace[i]; ok {
+			b.WriteString(line)
+		} else {
+			fmt.Fprintf(&b, "l%d\n", i)
+		}
+	}
+	return b.String()
+}
+
+// The expected hunks match the output of GNU diff -u.
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		context  int
+		want     string
+	}{
+		{name: "equal", old: "a\n", new: "a\n", context: 3, want: ""},
+		{name: "empty to non-empty", old: "", new: "a\nb\n", context: 3, want: "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{name: "non-empty to empty", old: "a\nb\n", new: "", context: 3, want: "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{
+			name: "old side without trailing newline", old: "a\nb", new: "a\nb\n", context: 3,
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "new side without trailing newline", old: "a\nb\n", new: "a\nc", context: 3,
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n",
+		},
+		{name: "CRLF", old: "a\r\nb\r\n", new: "a\r\nc\r\n", context: 3, want: "@@ -1,2 +1,2 @@\n a\r\n-b\r\n+c\r\n"},
+		{
+			// No line is unique, so there are no patience anchors and the
+			// whole region goes through Myers.
+			name: "duplicate lines", old: "x\ny\nx\ny\nx\n", new: "y\nx\ny\nx\ny\n", context: 3,
+			want: "@@ -1,5 +1,5 @@\n-x\n y\n x\n y\n x\n+y\n",
+		},
+		{
+			name: "changes sharing context", old: numbered(20, nil), new: numbered(20, map[int]string{5: "five\n", 12: "twelve\n"}), context: 3,
+			want: "@@ -2,14 +2,14 @@\n l2\n l3\n l4\n-l5\n+five\n l6\n l7\n l8\n l9\n l10\n l11\n-l12\n+twelve\n l13\n l14\n l15\n",
+		},
+		{
+			name: "changes one line too far apart", old: numbered(20, nil), new: numbered(20, map[int]string{5: "five\n", 13: "thirteen\n"}), context: 3,
+			want: "@@ -2,7 +2,7 @@\n l2\n l3\n l4\n-l5\n+five\n l6\n l7\n l8\n" +
+				"@@ -10,7 +10,7 @@\n l10\n l11\n l12\n-l13\n+thirteen\n l14\n l15\n l16\n",
+		},
+		{
+			name: "no context", old: numbered(10, nil), new: numbered(10, map[int]string{1: "", 10: "ten\n"}), context: 0,
+			want: "@@ -1 +0,0 @@\n-l1\n@@ -10 +9 @@\n-l10\n+ten\n",
+		},
+		{
+			name: "uneven hunk counts", old: numbered(10, nil), new: numbered(10, map[int]string{4: "", 7: "7a\n7b\n"}), context: 1,
+			want: "@@ -3,6 +3,6 @@\n l3\n-l4\n l5\n l6\n-l7\n+7a\n+7b\n l8\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff(tt.old, tt.new, DiffOptions{OldName: "old", NewName: "new", Context: tt.context})
+			want := tt.want
+			if want != "" {
+				want = "--- old\n+++ new\n" + want
+			}
+			if got != want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestUnifiedDiffRoundTrip checks that applying the diff of a and b to a
+// gives b.
+func TestUnifiedDiffRoundTrip(t *testing.T) {
+	type pair struct{ old, new string }
+	pairs := []pair{
+		{"", "a\n"},
+		{"a\n", ""},
+		{"a\nb", "a\nb\n"},
+		{"a\nb\n", "a\nc"},
+		{"a", "b"},
+		{"a\r\nb\r\nc\r\n", "a\r\nB\r\nc\r\nd\r\n"},
+		{"x\ny\nx\ny\nx\n", "y\nx\ny\nx\ny\n"},
+		{numbered(20, nil), numbered(20, map[int]string{5: "five\n", 13: "thirteen\n"})},
+	}
+	words := []string{"a", "b", "c", "", "\ta", "}"}
+	random := func(r *rand.Rand) string {
+		var b strings.Builder
+		n := r.Intn(12)
+		for i := 0; i < n; i++ {
+			b.WriteString(words[r.Intn(len(words))])
+			if i < n-1 || r.Intn(4) > 0 {
+				b.WriteByte('\n')
+			}
+		}
+		return b.String()
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		pairs = append(pairs, pair{random(r), random(r)})
+	}
+
+	for _, p := range pairs {
+		for context := 0; context <= 3; context++ {
+			diff := UnifiedDiff(p.old, p.new, DiffOptions{OldName: "a/f", NewName: "b/f", Context: context})
+			if diff == "" {
+				if p.old != p.new {
+					t.Fatalf("no diff between %q and %q", p.old, p.new)
+				}
+				continue
+			}
+			files, err := ParsePatch(diff)
+			if err != nil {
+				t.Fatalf("ParsePatch(%q): %v", diff, err)
+			}
+			got, _, err := ApplyHunks("f", p.old, files[0].Hunks)
+			if err != nil {
+				t.Fatalf("ApplyHunks(%q, %q): %v", p.old, diff, err)
+			}
+			if got != p.new {
+				t.Fatalf("applying\n%s\nto %q gave %q, want %q", diff, p.old, got, p.new)
+			}
+		}
+	}
+}
+
+func TestHighlightWords(t *testing.T) {
+	tests := []struct {
+		name             string
+		old, new         string
+		wantOld, wantNew string
+		wantOK           bool
+	}{
+		{
+			name: "one word", old: "return a + b", new: "return a - b",
+			wantOld: "return a " + colorReverse + "+" + colorNoRev + " b",
+			wantNew: "return a " + colorReverse + "-" + colorNoRev + " b",
+			wantOK:  true,
+		},
+		{
+			name: "adjacent words share a span", old: "x := foo(1)", new: "x := bar.baz(1)",
+			wantOld: "x := " + colorReverse + "foo" + colorNoRev + "(1)",
+			wantNew: "x := " + colorReverse + "bar.baz" + colorNoRev + "(1)",
+			wantOK:  true,
+		},
+		{
+			name: "insertion at the end", old: "a b", new: "a b c",
+			wantOld: "a b",
+			wantNew: "a b" + colorReverse + " c" + colorNoRev,
+			wantOK:  true,
+		},
+		{name: "unrelated lines", old: "foo bar", new: "baz qux"},
+		{name: "empty lines", old: "", new: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOld, gotNew, ok := highlightWords(tt.old, tt.new)
+			if ok != tt.wantOK || gotOld != tt.wantOld || gotNew != tt.wantNew {
+				t.Errorf("highlightWords(%q, %q) = %q, %q, %v, want %q, %q, %v", tt.old, tt.new, gotOld, gotNew, ok, tt.wantOld, tt.wantNew, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestColorizeDiff(t *testing.T) {
+	diff := "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n ctx\n-return a + b\n-gone\n+return a - b\n+something else entirely\n+new\n"
+	want := colorBold + "--- a/f" + colorReset + "\n" +
+		colorBold + "+++ b/f" + colorReset + "\n" +
+		colorCyan + "@@ -1,4 +1,4 @@" + colorReset + "\n" +
+		" ctx\n" +
+		colorRed + "-return a " + colorReverse + "+" + colorNoRev + " b" + colorReset + "\n" +
+		colorRed + "-gone" + colorReset + "\n" +
+		colorGreen + "+return a " + colorReverse + "-" + colorNoRev + " b" + colorReset + "\n" +
+		colorGreen + "+something else entirely" + colorReset + "\n" +
+		colorGreen + "+new" + colorReset + "\n"
+	if got := ColorizeDiff(diff); got != want {
+		t.Errorf("ColorizeDiff() =\n%q\nwant\n%q", got, want)
+	}
+}
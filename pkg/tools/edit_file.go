@@ -1,40 +1,110 @@
 package tools
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"google.golang.org/genai"
 )
 
-const editFileDescription = `Edit the contents of the file at the given relative 'path' argument by replacing instances of 'old_string' with 'new_string'.` +
-	` 'old_string' and 'new_string' must be different from each other. Before making any changes,you will output a plan of the proposed modifications show the diff between the changes using the diff tool and will ask for your approval.` +
-	` If the file specified in 'path' does not exist, it will be created. DO NOT EDIT FILES WITHOUT EXPLICIT APPROVAL. ALWAYS PRESENT A PLAN OF CHANGES AND ASK FOR CONFIRMATION BEFORE PROCEEDING.`
-
-var editFileTool = &genai.Tool{
-	FunctionDeclarations: []*genai.FunctionDeclaration{
-		{
-			Description: editFileDescription,
-			Name:        "edit_file",
-			Parameters: &genai.Schema{
-				Type: genai.TypeObject,
-				Properties: map[string]*genai.Schema{
-					"path": {
-						Type: genai.TypeString,
-					},
-					"old_string": {
-						Type: genai.TypeString,
-					},
-					"new_string": {
-						Type: genai.TypeString,
-					},
-				},
-			},
+const editFileDescription = `Edit the contents of the file at the given relative 'path' argument by replacing 'old_string' with 'new_string'.` +
+	` 'old_string' and 'new_string' must be different from each other. 'old_string' must match exactly one place in the file; if it matches several,` +
+	` include more surrounding lines to make it unique, set 'occurrence' to pick one, or set 'replace_all' to replace every match.` +
+	` Before making any changes, output a plan of the proposed modifications; the diff tool with the same 'path', 'old_string' and 'new_string' previews the result.` +
+	` If the file specified in 'path' does not exist and 'old_string' is empty, it will be created. The user is shown the diff and must approve the edit before it is applied;` +
+	` if they reject it the response says so and may contain their feedback, so adjust the plan accordingly.`
+
+// Edit replaces OldString in a file. It must match exactly once unless
+// ReplaceAll is set or Occurrence picks one of the matches.
+type Edit struct {
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all"`
+	// Occurrence is the 1-based index of the match to replace.
+	Occurrence int `json:"occurrence"`
+}
+
+type editFileArgs struct {
+	Path string `json:"path"`
+	Edit
+}
+
+// editProperties are the parameters describing one Edit, shared by edit_file
+// and multi_edit.
+func editProperties() map[string]*genai.Schema {
+	return map[string]*genai.Schema{
+		"old_string": {
+			Type:        genai.TypeString,
+			Description: "The exact text to replace, including whitespace and indentation",
+		},
+		"new_string": {
+			Type:        genai.TypeString,
+			Description: "The text to replace it with",
+		},
+		"replace_all": {
+			Type:        genai.TypeBoolean,
+			Description: "Replace every match of old_string (default: false)",
+		},
+		"occurrence": {
+			Type:        genai.TypeInteger,
+			Description: "Replace only this match of old_string, counting from 1 at the top of the file",
+			Minimum:     genai.Ptr(1.0),
+		},
+	}
+}
+
+var editFileTool = Func[editFileArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: editFileDescription,
+		Name:        "edit_file",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: func() map[string]*genai.Schema {
+				props := editProperties()
+				props["path"] = &genai.Schema{Type: genai.TypeString}
+				return props
+			}(),
+			Required: []string{"path", "old_string", "new_string"},
 		},
 	},
+	Run: func(ctx context.Context, env *Env, args editFileArgs) (string, error) {
+		path, err := env.Workspace.Resolve(args.Path, true)
+		if err != nil {
+			return "", err
+		}
+		if err := env.checkpoint("edit_file", []string{path}, func() error { return EditFile(path, args.Edit) }); err != nil {
+			return "", err
+		}
+		return "OK" + env.editDiagnostics(ctx, []string{path}), nil
+	},
+	PreviewRun: func(ctx context.Context, env *Env, args editFileArgs) (*ApprovalRequest, error) {
+		return previewEdits(env, args.Path, []Edit{args.Edit})
+	},
+}
+
+func init() {
+	builtins = append(builtins, editFileTool)
+}
+
+// previewEdits shows the diff the edits would make to the file.
+func previewEdits(env *Env, filePath string, edits []Edit) (*ApprovalRequest, error) {
+	path, err := env.Workspace.Resolve(filePath, true)
+	if err != nil {
+		return nil, err
+	}
+	oldContent, newContent, err := editedContent(path, edits)
+	if err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(path)
+	created := errors.Is(statErr, fs.ErrNotExist)
+	return &ApprovalRequest{Summary: fmt.Sprintf("Edit %s", filePath), Preview: FileDiff(filePath, oldContent, newContent, created)}, nil
 }
 
 func CreateNewFile(filePath, content string) error {
@@ -54,31 +124,94 @@ func CreateNewFile(filePath, content string) error {
 	return nil
 }
 
-func EditFile(path, oldStr, newStr string) error {
+// EditFile applies the edits to the file in order. Either all of them are
+// applied or, when one fails, the file is left unchanged. A missing file is
+// created when the first edit has an empty OldString.
+func EditFile(path string, edits ...Edit) error {
 	if path == "" {
 		return errors.New("Invalid argument: file path is empty.")
 	}
+	_, newContent, err := editedContent(path, edits)
+	if err != nil {
+		return err
+	}
+	return ApplyChanges([]FileChange{{Path: path, Content: []byte(newContent)}})
+}
 
+// editedContent returns the current content of the file and its content
+// after the edits.
+func editedContent(path string, edits []Edit) (string, string, error) {
+	if len(edits) == 0 {
+		return "", "", errors.New("Invalid argument: no edits given.")
+	}
 	oldContent, err := ReadFile(path)
+	content := oldContent
 	if err != nil {
-		if os.IsNotExist(err) && oldStr == "" {
-			return CreateNewFile(path, newStr)
+		if !errors.Is(err, fs.ErrNotExist) || edits[0].OldString != "" {
+			return "", "", err
 		}
-		return err
+		oldContent, content = "", edits[0].NewString
+		edits = edits[1:]
 	}
 
-	if oldStr == "" {
-		return errors.New("Invalid argument: 'old_string' is empty.")
+	for i, edit := range edits {
+		content, err = replaceEdit(content, edit)
+		if err != nil {
+			if len(edits) > 1 {
+				return "", "", fmt.Errorf("failed to edit %s: edit %d: %w; no edits were applied", path, i+1, err)
+			}
+			return "", "", fmt.Errorf("failed to edit %s: %w", path, err)
+		}
 	}
+	return oldContent, content, nil
+}
 
-	newContent := strings.Replace(oldContent, oldStr, newStr, -1)
-	if oldContent == newContent && oldStr != "" {
-		return fmt.Errorf("'old_string' not found in %s", path)
+// replaceEdit applies one edit to content.
+func replaceEdit(content string, edit Edit) (string, error) {
+	if edit.OldString == "" {
+		return "", errors.New("Invalid argument: 'old_string' is empty")
+	}
+	if edit.OldString == edit.NewString {
+		return "", errors.New("Invalid argument: 'old_string' and 'new_string' are the same")
+	}
+	if edit.ReplaceAll && edit.Occurrence > 0 {
+		return "", errors.New("Invalid argument: set either 'replace_all' or 'occurrence', not both")
 	}
 
-	err = os.WriteFile(path, []byte(newContent), 0644)
-	if err != nil {
-		return err
+	matches := matchOffsets(content, edit.OldString)
+	switch {
+	case len(matches) == 0:
+		return "", errors.New("'old_string' not found")
+	case edit.ReplaceAll:
+		return strings.ReplaceAll(content, edit.OldString, edit.NewString), nil
+	case edit.Occurrence > len(matches):
+		return "", fmt.Errorf("occurrence %d requested but 'old_string' matches %d times (at lines %s)", edit.Occurrence, len(matches), matchLines(content, matches))
+	case edit.Occurrence == 0 && len(matches) > 1:
+		return "", fmt.Errorf("'old_string' matches %d times (at lines %s); include more surrounding context to make it unique, or set 'occurrence' or 'replace_all'", len(matches), matchLines(content, matches))
 	}
-	return nil
+
+	at := matches[max(edit.Occurrence, 1)-1]
+	return content[:at] + edit.NewString + content[at+len(edit.OldString):], nil
+}
+
+// matchOffsets returns the byte offsets of the non-overlapping matches of s.
+func matchOffsets(content, s string) []int {
+	var offsets []int
+	for at := 0; ; {
+		i := strings.Index(content[at:], s)
+		if i < 0 {
+			return offsets
+		}
+		offsets = append(offsets, at+i)
+		at += i + len(s)
+	}
+}
+
+// matchLines formats the line numbers of the matches, e.g. "12, 40, 97".
+func matchLines(content string, offsets []int) string {
+	lines := make([]string, len(offsets))
+	for i, offset := range offsets {
+		lines[i] = strconv.Itoa(strings.Count(content[:offset], "\n") + 1)
+	}
+	return strings.Join(lines, ", ")
 }
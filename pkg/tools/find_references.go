@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+
+	"google.golang.org/genai"
+)
+
+const findReferencesDescription = `Find every reference to a Go identifier across the packages of the workspace, including tests, using the type checker, ` +
+	`so shadowed and unrelated identifiers with the same name are not reported. ` +
+	`Name a package-level declaration with 'symbol' ('Name', 'Type.Method' or 'Type.Field') in the package of the file at 'path', ` +
+	`or any identifier, including local variables, with 'symbol' and the 'line' it appears on in that file.`
+
+const maxGoReferences = 500
+
+type findReferencesArgs struct {
+	Path   string `json:"path"`
+	Symbol string `json:"symbol"`
+	Line   int    `json:"line"`
+}
+
+// GoReferences is the output of find_references.
+type GoReferences struct {
+	Symbol     string         `json:"symbol"`
+	Kind       string         `json:"kind"`
+	References []*GoReference `json:"references"`
+	Truncated  bool           `json:"truncated,omitempty"`
+}
+
+// goSymbolProperties are the parameters naming a Go identifier, shared by
+// find_references and rename_symbol.
+func goSymbolProperties() map[string]*genai.Schema {
+	return map[string]*genai.Schema{
+		"path": {
+			Type:        genai.TypeString,
+			Description: "A .go file in the package declaring the symbol, or containing the identifier",
+		},
+		"symbol": {
+			Type:        genai.TypeString,
+			Description: "The identifier, e.g. 'New', 'Tools.Register' or, with 'line', a local variable name",
+		},
+		"line": {
+			Type:        genai.TypeInteger,
+			Description: "The line of the file the identifier appears on, to name local identifiers",
+			Minimum:     genai.Ptr(1.0),
+		},
+	}
+}
+
+var findReferencesTool = Func[findReferencesArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: findReferencesDescription,
+		Name:        "find_references",
+		Parameters: &genai.Schema{
+			Type:       genai.TypeObject,
+			Properties: goSymbolProperties(),
+			Required:   []string{"path", "symbol"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args findReferencesArgs) (string, error) {
+		path, err := env.Workspace.Resolve(args.Path, false)
+		if err != nil {
+			return "", err
+		}
+		prog, err := loadGoProgram(ctx, env.Workspace.Dir(), nil)
+		if err != nil {
+			return "", err
+		}
+		obj, err := prog.objectAt(path, args.Symbol, args.Line)
+		if err != nil {
+			return "", err
+		}
+		result := GoReferences{Symbol: args.Symbol, Kind: describeObject(obj), References: prog.references(obj)}
+		if len(result.References) > maxGoReferences {
+			result.References, result.Truncated = result.References[:maxGoReferences], true
+		}
+		return marshalOutput(result)
+	},
+}
+
+func init() {
+	builtins = append(builtins, findReferencesTool)
+}
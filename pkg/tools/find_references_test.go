@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindReferences(t *testing.T) {
+	ws, err := NewWorkspace("testdata/gorefs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &Env{Workspace: ws}
+
+	tests := []struct {
+		name     string
+		args     findReferencesArgs
+		wantKind string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name:     "function across packages and tests",
+			args:     findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Scale"},
+			wantKind: "func",
+			want:     []string{"main.go:12", "shapes/label_test.go:11", "shapes/shapes.go:22*"},
+		},
+		{
+			name:     "method used from a test",
+			args:     findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Square.Area"},
+			wantKind: "method",
+			want:     []string{"main.go:12", "shapes/shapes.go:17*", "shapes/shapes.go:23", "shapes/shapes_test.go:6"},
+		},
+		{
+			name:     "type used as an embedded field",
+			args:     findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Label"},
+			wantKind: "type",
+			want: []string{
+				"main.go:11",
+				"shapes/label_test.go:10", "shapes/label_test.go:10", "shapes/label_test.go:11",
+				"shapes/shapes.go:6*", "shapes/shapes.go:12",
+			},
+		},
+		{
+			name:     "local variable by line",
+			args:     findReferencesArgs{Path: "shapes/shapes.go", Symbol: "size", Line: 32},
+			wantKind: "var",
+			want:     []string{"shapes/shapes.go:32*", "shapes/shapes.go:33", "shapes/shapes.go:34", "shapes/shapes.go:36"},
+		},
+		{
+			name:    "local variable without line",
+			args:    findReferencesArgs{Path: "shapes/shapes.go", Symbol: "size"},
+			wantErr: "pass 'line' to name a local identifier",
+		},
+		{
+			name:    "unknown member",
+			args:    findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Square.Volume"},
+			wantErr: "type Square has no field or method 'Volume'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			out, err := findReferencesTool.Run(context.Background(), env, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result GoReferences
+			if err := json.Unmarshal([]byte(out), &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Kind != tt.wantKind {
+				t.Errorf("kind = %q, want %q", result.Kind, tt.wantKind)
+			}
+			var got []string
+			for _, ref := range result.References {
+				loc := fmt.Sprintf("%s:%d", ref.File, ref.Line)
+				if ref.Declaration {
+					loc += "*"
+				}
+				got = append(got, loc)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("references = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultGitOutputBytes caps the text, such as diffs, returned by the git
+// tools.
+const defaultGitOutputBytes = 64 * 1024
+
+// GitFileStat is the number of lines a change adds to and removes from a
+// file. Binary files have no line counts.
+type GitFileStat struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// GitCommitInfo describes one commit.
+type GitCommitInfo struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body,omitempty"`
+}
+
+// gitLogFormat separates the fields of a commit with US and commits with RS
+// so subjects and bodies can hold any other character.
+const gitLogFormat = "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%s%x1f%b%x1e"
+
+// Git runs a git command in dir and returns its output, capped at maxBytes.
+// Optional locks are disabled so that read-only commands such as status never
+// write to the index while the user works in the same repository.
+func Git(ctx context.Context, dir string, maxBytes int, args ...string) (string, bool, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultGitOutputBytes
+	}
+	name := args[0]
+	args = append([]string{"-c", "color.ui=false", "-c", "core.quotepath=off"}, args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0", "GIT_PAGER=cat", "LC_ALL=C")
+	stdout := &cappedBuffer{max: maxBytes}
+	var stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", false, fmt.Errorf("git %s failed: %s", name, msg)
+		}
+		return "", false, fmt.Errorf("git %s failed: %w", name, err)
+	}
+	return stdout.String(), stdout.truncated, nil
+}
+
+// checkRef rejects revisions that git would parse as options.
+func checkRef(ref string) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid revision '%s'", ref)
+	}
+	return nil
+}
+
+// gitPaths resolves the paths the model passed to git pathspecs within the
+// workspace. Pathspec magic is disabled, so the paths are always literal.
+func (env *Env) gitPaths(paths []string) ([]string, error) {
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		abs, err := env.Workspace.Resolve(path, false)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, ":(literal)"+abs)
+	}
+	return resolved, nil
+}
+
+// truncateLines cuts text that was truncated at a byte limit back to the last
+// complete line.
+func truncateLines(text string, truncated bool) string {
+	if !truncated {
+		return text
+	}
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		return text[:i+1]
+	}
+	return ""
+}
+
+// parseNumstat parses the output of 'git diff --numstat -z'.
+func parseNumstat(out string) []GitFileStat {
+	var stats []GitFileStat
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		counts := strings.SplitN(fields[i], "\t", 3)
+		if len(counts) != 3 {
+			continue
+		}
+		stat := GitFileStat{Path: counts[2]}
+		if counts[0] == "-" && counts[1] == "-" {
+			stat.Binary = true
+		} else {
+			stat.Additions, _ = strconv.Atoi(counts[0])
+			stat.Deletions, _ = strconv.Atoi(counts[1])
+		}
+		// Renames leave the path empty and are followed by the old and new
+		// paths as separate fields.
+		if stat.Path == "" && i+2 < len(fields) {
+			stat.OldPath, stat.Path = fields[i+1], fields[i+2]
+			i += 2
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// parseLog parses commits printed with gitLogFormat.
+func parseLog(out string) ([]GitCommitInfo, error) {
+	var commits []GitCommitInfo
+	for record := range strings.SplitSeq(out, "\x1e") {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+		fields := strings.Split(record, "\x1f")
+		if len(fields) != 6 {
+			return nil, errors.New("unexpected git log output")
+		}
+		date, err := time.Parse(time.RFC3339, fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git log date '%s'", fields[3])
+		}
+		commits = append(commits, GitCommitInfo{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Email:   fields[2],
+			Date:    date,
+			Subject: fields[4],
+			Body:    strings.TrimSpace(fields[5]),
+		})
+	}
+	return commits, nil
+}
+
+// marshalOutput encodes the structured output of a tool.
+func marshalOutput(v any) (string, error) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling JSON: %w", err)
+	}
+	return string(out), nil
+}
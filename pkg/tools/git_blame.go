@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const gitBlameDescription = `Show which commit last changed each line of a file in the git repository, with its author, date and subject. ` +
+	`Blame a range with 'start_line' and 'end_line'; at most 500 lines are returned per call. ` +
+	`Use git_show with a returned commit to see the whole change.`
+
+const maxGitBlameLines = 500
+
+type gitBlameArgs struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Ref       string `json:"ref"`
+}
+
+// GitBlame is the output of git_blame.
+type GitBlame struct {
+	Lines []BlameLine `json:"lines"`
+	// Truncated is set when the range was longer than the lines returned.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// BlameLine is one line of a file and the commit that last changed it.
+type BlameLine struct {
+	Line    int       `json:"line"`
+	Commit  string    `json:"commit"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Summary string    `json:"summary"`
+	Text    string    `json:"text"`
+}
+
+var gitBlameTool = Func[gitBlameArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: gitBlameDescription,
+		Name:        "git_blame",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"path": {
+					Type:        genai.TypeString,
+					Description: "The file to blame",
+				},
+				"start_line": {
+					Type:        genai.TypeInteger,
+					Description: "First line to blame, counting from 1 (default: 1)",
+					Minimum:     genai.Ptr(1.0),
+				},
+				"end_line": {
+					Type:        genai.TypeInteger,
+					Description: "Last line to blame (default: start_line plus 499, or the end of the file)",
+					Minimum:     genai.Ptr(1.0),
+				},
+				"ref": {
+					Type:        genai.TypeString,
+					Description: "Blame the file as of this commit instead of the working tree",
+				},
+			},
+			Required: []string{"path"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args gitBlameArgs) (string, error) {
+		if err := checkRef(args.Ref); err != nil {
+			return "", err
+		}
+		path, err := env.Workspace.Resolve(args.Path, false)
+		if err != nil {
+			return "", err
+		}
+		blame, err := GitBlameOf(ctx, env.Workspace.Dir(), path, args.Ref, args.StartLine, args.EndLine)
+		if err != nil {
+			return "", err
+		}
+		return marshalOutput(blame)
+	},
+}
+
+func init() {
+	builtins = append(builtins, gitBlameTool)
+}
+
+// GitBlameOf blames lines start to end of the file at path. At most
+// maxGitBlameLines lines are returned.
+func GitBlameOf(ctx context.Context, dir, path, ref string, start, end int) (*GitBlame, error) {
+	start = max(start, 1)
+	if end != 0 && end < start {
+		return nil, fmt.Errorf("end_line %d is before start_line %d", end, start)
+	}
+	blame := &GitBlame{Lines: []BlameLine{}}
+	last := start + maxGitBlameLines - 1
+	if end != 0 && end <= last {
+		last = end
+	} else if end > last {
+		blame.Truncated = true
+	}
+
+	// Ask for one line more than returned to tell whether the file goes on.
+	args := []string{"blame", "--porcelain", fmt.Sprintf("-L%d,%d", start, last+1)}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	out, _, err := Git(ctx, dir, 4*1024*1024, append(args, "--", path)...)
+	if err != nil && strings.Contains(err.Error(), "has only") {
+		// The file ends before last+1; blame up to its end.
+		args[2] = fmt.Sprintf("-L%d,", start)
+		out, _, err = Git(ctx, dir, 4*1024*1024, append(args, "--", path)...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make(map[string]*BlameLine)
+	var current *BlameLine
+	var hash string
+	for line := range strings.SplitSeq(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			if current == nil {
+				continue
+			}
+			current.Text = line[1:]
+			if current.Line <= last {
+				blame.Lines = append(blame.Lines, *current)
+			} else if end == 0 {
+				blame.Truncated = true
+			}
+			current = nil
+		case current == nil:
+			// <hash> <original line> <final line> [<lines in group>]
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			hash = fields[0]
+			info := commits[hash]
+			if info == nil {
+				info = &BlameLine{Commit: hash[:min(12, len(hash))]}
+				commits[hash] = info
+			}
+			line := *info
+			line.Line, _ = strconv.Atoi(fields[2])
+			current = &line
+		default:
+			// The header lines following the first line of a commit
+			// describe it.
+			key, value, _ := strings.Cut(line, " ")
+			info := commits[hash]
+			switch key {
+			case "author":
+				info.Author, current.Author = value, value
+			case "author-time":
+				if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+					info.Date = time.Unix(secs, 0).UTC()
+					current.Date = info.Date
+				}
+			case "summary":
+				info.Summary, current.Summary = value, value
+			}
+		}
+	}
+	return blame, nil
+}
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const gitBranchDescription = `List, create or switch git branches. 'list' returns the local branches (and remote ones with 'remote') with the current one marked. ` +
+	`'create' creates branch 'name' from 'start_point' (default: HEAD) and switches to it, which is the way to try risky changes without touching the current branch. ` +
+	`'switch' checks out an existing branch; git refuses when uncommitted changes would be overwritten. ` +
+	`Creating and switching branches must be approved by the user.`
+
+type gitBranchArgs struct {
+	Action     string `json:"action"`
+	Name       string `json:"name"`
+	StartPoint string `json:"start_point"`
+	Remote     bool   `json:"remote"`
+}
+
+// GitBranch is one branch listed by git_branch.
+type GitBranch struct {
+	Name     string `json:"name"`
+	Current  bool   `json:"current,omitempty"`
+	Upstream string `json:"upstream,omitempty"`
+	Commit   string `json:"commit"`
+	Subject  string `json:"subject"`
+}
+
+var gitBranchTool = Func[gitBranchArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: gitBranchDescription,
+		Name:        "git_branch",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"action": {
+					Type: genai.TypeString,
+					Enum: []string{"list", "create", "switch"},
+				},
+				"name": {
+					Type:        genai.TypeString,
+					Description: "The branch to create or switch to",
+				},
+				"start_point": {
+					Type:        genai.TypeString,
+					Description: "The commit or branch to create the branch from (default: HEAD)",
+				},
+				"remote": {
+					Type:        genai.TypeBoolean,
+					Description: "Also list remote-tracking branches (default: false)",
+				},
+			},
+			Required: []string{"action"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args gitBranchArgs) (string, error) {
+		if err := args.check(); err != nil {
+			return "", err
+		}
+		dir := env.Workspace.Dir()
+		switch args.Action {
+		case "create":
+			cmd := []string{"switch", "--create", args.Name}
+			if args.StartPoint != "" {
+				cmd = append(cmd, args.StartPoint)
+			}
+			if _, _, err := Git(ctx, dir, 0, cmd...); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Created and switched to branch '%s'", args.Name), nil
+		case "switch":
+			if _, _, err := Git(ctx, dir, 0, "switch", args.Name); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Switched to branch '%s'", args.Name), nil
+		default:
+			branches, err := GitBranches(ctx, dir, args.Remote)
+			if err != nil {
+				return "", err
+			}
+			return marshalOutput(branches)
+		}
+	},
+	PreviewRun: func(ctx context.Context, env *Env, args gitBranchArgs) (*ApprovalRequest, error) {
+		if err := args.check(); err != nil {
+			return nil, err
+		}
+		switch args.Action {
+		case "create":
+			from := args.StartPoint
+			if from == "" {
+				from = "HEAD"
+			}
+			return &ApprovalRequest{Summary: fmt.Sprintf("Create and switch to branch '%s' from %s", args.Name, from)}, nil
+		case "switch":
+			status, err := GitStatusOf(ctx, env.Workspace.Dir())
+			if err != nil {
+				return nil, err
+			}
+			preview := fmt.Sprintf("from '%s', with %d uncommitted change(s) in the working tree", status.Branch, len(status.Files))
+			return &ApprovalRequest{Summary: fmt.Sprintf("Switch to branch '%s'", args.Name), Preview: preview}, nil
+		default:
+			return nil, nil
+		}
+	},
+}
+
+func init() {
+	builtins = append(builtins, gitBranchTool)
+}
+
+func (args gitBranchArgs) check() error {
+	if args.Action == "list" {
+		return nil
+	}
+	if args.Name == "" {
+		return fmt.Errorf("'name' is required to %s a branch", args.Action)
+	}
+	if strings.HasPrefix(args.Name, "-") {
+		return fmt.Errorf("invalid branch name '%s'", args.Name)
+	}
+	return checkRef(args.StartPoint)
+}
+
+// GitBranches lists the local branches of the repository containing dir and,
+// with remote, the remote-tracking ones.
+func GitBranches(ctx context.Context, dir string, remote bool) ([]GitBranch, error) {
+	refs := []string{"refs/heads"}
+	if remote {
+		refs = append(refs, "refs/remotes")
+	}
+	format := "--format=%(refname:short)%1f%(HEAD)%1f%(upstream:short)%1f%(objectname:short)%1f%(contents:subject)"
+	out, _, err := Git(ctx, dir, 0, append([]string{"for-each-ref", "--sort=refname", format}, refs...)...)
+	if err != nil {
+		return nil, err
+	}
+	branches := []GitBranch{}
+	for line := range strings.SplitSeq(strings.TrimRight(out, "\n"), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		branches = append(branches, GitBranch{
+			Name:     fields[0],
+			Current:  fields[1] == "*",
+			Upstream: fields[2],
+			Commit:   fields[3],
+			Subject:  fields[4],
+		})
+	}
+	return branches, nil
+}
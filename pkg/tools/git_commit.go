@@ -1,47 +1,231 @@
 package tools
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"strings"
 
 	"google.golang.org/genai"
 )
 
-const gitCommitDescription = `Stages all changes and creates a new Git commit with the given message. DONT COMMIT WITHOUT APPROVAL. ALWAYS ASK THE USER FOR APPROVAL`
-
-var gitCommitTool = &genai.Tool{
-	FunctionDeclarations: []*genai.FunctionDeclaration{
-		{
-			Description: gitCommitDescription,
-			Name:        "git_commit",
-			Parameters: &genai.Schema{
-				Type: genai.TypeObject,
-				Properties: map[string]*genai.Schema{
-					"message": {
-						Type:        genai.TypeString,
-						Description: "The commit message.",
-					},
+const gitCommitDescription = `Stage and commit files to the git repository. By default only the files you changed with the file tools in this session are committed; ` +
+	`pass 'paths' to choose the files or directories to commit instead. Other changes, including ones already staged, are left out of the commit. ` +
+	`Commits are refused while the repository has unmerged files. Set 'amend' to replace the last commit, 'sign_off' to add a Signed-off-by trailer ` +
+	`and 'author' ('Name <email>') to override the author. Returns the new commit hash and a --stat summary. ` +
+	`The user is shown the message and the files and must approve the commit; if they reject it the response says so and may contain their feedback.`
+
+type gitCommitArgs struct {
+	Message string   `json:"message"`
+	Paths   []string `json:"paths"`
+	Amend   bool     `json:"amend"`
+	SignOff bool     `json:"sign_off"`
+	Author  string   `json:"author"`
+}
+
+// CommitOptions describe a commit made by GitCommit.
+type CommitOptions struct {
+	// Message is the commit message. It may be empty when amending to keep
+	// the message of the last commit.
+	Message string
+	// Pathspecs are staged and committed; nothing else is. They may be empty
+	// only when amending, which then changes just the message or author.
+	Pathspecs []string
+	Amend     bool
+	SignOff   bool
+	// Author overrides the commit author, as "Name <email>".
+	Author string
+}
+
+var gitCommitTool = Func[gitCommitArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: gitCommitDescription,
+		Name:        "git_commit",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"message": {
+					Type:        genai.TypeString,
+					Description: "The commit message. May be omitted with 'amend' to keep the last commit's message.",
+				},
+				"paths": {
+					Type:        genai.TypeArray,
+					Items:       &genai.Schema{Type: genai.TypeString},
+					Description: "Files or directories to commit (default: the files changed in this session)",
+				},
+				"amend": {
+					Type:        genai.TypeBoolean,
+					Description: "Replace the last commit instead of creating a new one (default: false)",
+				},
+				"sign_off": {
+					Type:        genai.TypeBoolean,
+					Description: "Add a Signed-off-by trailer (default: false)",
+				},
+				"author": {
+					Type:        genai.TypeString,
+					Description: "Override the commit author, as 'Name <email>'",
 				},
-				Required: []string{"message"},
 			},
 		},
 	},
+	Run: func(ctx context.Context, env *Env, args gitCommitArgs) (string, error) {
+		plan, err := env.planCommit(ctx, args)
+		if err != nil {
+			return "", err
+		}
+		return GitCommit(ctx, env.Workspace.Dir(), plan.options)
+	},
+	PreviewRun: func(ctx context.Context, env *Env, args gitCommitArgs) (*ApprovalRequest, error) {
+		plan, err := env.planCommit(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		var preview strings.Builder
+		message := args.Message
+		if message == "" {
+			message = "(unchanged)"
+		}
+		fmt.Fprintf(&preview, "Message:\n%s\n", message)
+		if args.Author != "" {
+			fmt.Fprintf(&preview, "\nAuthor: %s\n", args.Author)
+		}
+		if args.SignOff {
+			preview.WriteString("\nWith a Signed-off-by trailer\n")
+		}
+		if len(plan.files) > 0 {
+			preview.WriteString("\nFiles to be staged and committed:\n")
+		}
+		for _, file := range plan.files {
+			state := file.Unstaged
+			if state == "" {
+				state = file.Staged
+			}
+			fmt.Fprintf(&preview, "  %-10s %s\n", state, file.Path)
+		}
+
+		summary := fmt.Sprintf("Commit %d file(s)", len(plan.files))
+		switch {
+		case args.Amend && len(plan.files) == 0:
+			summary = "Amend the last commit"
+		case args.Amend:
+			summary = fmt.Sprintf("Amend the last commit with %d file(s)", len(plan.files))
+		}
+		return &ApprovalRequest{Summary: summary, Preview: preview.String()}, nil
+	},
+}
+
+func init() {
+	builtins = append(builtins, gitCommitTool)
+}
+
+// commitPlan is what a git_commit call will commit.
+type commitPlan struct {
+	options CommitOptions
+	// files are the changed files being committed.
+	files []GitFileStatus
 }
 
-func GitCommit(message string) (string, error) {
-	// Stage all changes
-	cmd := exec.Command("git", "add", ".")
-	output, err := cmd.CombinedOutput()
+// planCommit checks that the repository can be committed to and works out
+// which changed files the call commits.
+func (env *Env) planCommit(ctx context.Context, args gitCommitArgs) (*commitPlan, error) {
+	if args.Message == "" && !args.Amend {
+		return nil, errors.New("a commit message is required")
+	}
+	if args.Author != "" && (strings.HasPrefix(args.Author, "-") || !strings.Contains(args.Author, "<") || !strings.HasSuffix(args.Author, ">")) {
+		return nil, fmt.Errorf("invalid author '%s', expected 'Name <email>'", args.Author)
+	}
+	dir := env.Workspace.Dir()
+
+	unmerged, _, err := Git(ctx, dir, 0, "diff", "--name-only", "--diff-filter=U", "-z")
 	if err != nil {
-		return "", fmt.Errorf("failed to stage changes: %w, output: %s", err, string(output))
+		return nil, err
+	}
+	if files := strings.Split(strings.TrimRight(unmerged, "\x00"), "\x00"); unmerged != "" {
+		return nil, fmt.Errorf("cannot commit while there are unmerged files, resolve the conflicts first: %s", strings.Join(files, ", "))
 	}
 
-	// Commit changes
-	cmd = exec.Command("git", "commit", "-m", message)
-	output, err = cmd.CombinedOutput()
+	var pathspecs []string
+	if len(args.Paths) > 0 {
+		if pathspecs, err = env.gitPaths(args.Paths); err != nil {
+			return nil, err
+		}
+	} else {
+		var modified []string
+		if env.Checkpoints != nil {
+			if modified, err = env.Checkpoints.Modified(); err != nil {
+				return nil, err
+			}
+		}
+		if len(modified) == 0 && !args.Amend {
+			return nil, errors.New("no files were changed in this session; pass 'paths' to choose the files to commit")
+		}
+		for _, path := range modified {
+			pathspecs = append(pathspecs, ":(literal)"+path)
+		}
+	}
+
+	plan := &commitPlan{options: CommitOptions{Message: args.Message, Amend: args.Amend, SignOff: args.SignOff, Author: args.Author}}
+	if len(pathspecs) == 0 {
+		return plan, nil
+	}
+	status, err := GitStatusOf(ctx, dir, pathspecs...)
 	if err != nil {
-		return "", fmt.Errorf("failed to commit changes: %w, output: %s", err, string(output))
+		return nil, err
+	}
+	if status.Truncated {
+		// Committing the listed files only would silently leave the rest out.
+		return nil, fmt.Errorf("too many changed files to commit at once (more than %d); pass 'paths' to commit them in smaller groups", len(status.Files))
+	}
+	if len(status.Files) == 0 && !args.Amend {
+		return nil, errors.New("nothing to commit: the files have no changes")
 	}
+	// Commit exactly the changed files. Paths from git status are relative
+	// to the repository root.
+	for _, file := range status.Files {
+		plan.files = append(plan.files, file)
+		plan.options.Pathspecs = append(plan.options.Pathspecs, ":(top,literal)"+file.Path)
+		if file.OldPath != "" {
+			plan.options.Pathspecs = append(plan.options.Pathspecs, ":(top,literal)"+file.OldPath)
+		}
+	}
+	return plan, nil
+}
 
-	return "Commit successful", nil
+// GitCommit stages the files matching the pathspecs in dir and commits only
+// them. It returns the new commit hash and the --stat summary of the commit.
+func GitCommit(ctx context.Context, dir string, opts CommitOptions) (string, error) {
+	if len(opts.Pathspecs) > 0 {
+		if _, _, err := Git(ctx, dir, 0, append([]string{"add", "--all", "--"}, opts.Pathspecs...)...); err != nil {
+			return "", fmt.Errorf("failed to stage changes: %w", err)
+		}
+	}
+
+	args := []string{"commit", "--only"}
+	if opts.Message != "" {
+		args = append(args, "--message", opts.Message)
+	} else {
+		args = append(args, "--no-edit")
+	}
+	if opts.Amend {
+		args = append(args, "--amend")
+	}
+	if opts.SignOff {
+		args = append(args, "--signoff")
+	}
+	if opts.Author != "" {
+		args = append(args, "--author", opts.Author)
+	}
+	if _, _, err := Git(ctx, dir, 0, append(append(args, "--"), opts.Pathspecs...)...); err != nil {
+		return "", fmt.Errorf("failed to commit changes: %w", err)
+	}
+
+	hash, _, err := Git(ctx, dir, 0, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	stat, _, err := Git(ctx, dir, 0, "show", "--stat", "--format=%s", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Committed %s: %s", strings.TrimSpace(hash), stat), nil
 }
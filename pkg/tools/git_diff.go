@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+const gitDiffDescription = `Show changes in the git repository as a unified diff together with per-file line counts. ` +
+	`By default shows the unstaged changes in the working tree; set 'staged' for the changes staged for the next commit, ` +
+	`or 'ref' to compare the working tree (or, with 'staged', the index) against a commit, branch or tag. ` +
+	`Limit the diff to some files with 'paths'. Long diffs are truncated; the file list is always complete.`
+
+type gitDiffArgs struct {
+	Staged       bool     `json:"staged"`
+	Ref          string   `json:"ref"`
+	Paths        []string `json:"paths"`
+	ContextLines *int     `json:"context_lines"`
+}
+
+// GitDiff is the output of git_diff and git_show.
+type GitDiff struct {
+	Files     []GitFileStat `json:"files"`
+	Diff      string        `json:"diff"`
+	Truncated bool          `json:"truncated,omitempty"`
+}
+
+var gitDiffTool = Func[gitDiffArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: gitDiffDescription,
+		Name:        "git_diff",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"staged": {
+					Type:        genai.TypeBoolean,
+					Description: "Show the staged changes instead of the unstaged ones (default: false)",
+				},
+				"ref": {
+					Type:        genai.TypeString,
+					Description: "Compare against this commit, branch or tag, e.g. 'HEAD~3' or 'main'",
+				},
+				"paths": {
+					Type:        genai.TypeArray,
+					Items:       &genai.Schema{Type: genai.TypeString},
+					Description: "Only diff these files or directories",
+				},
+				"context_lines": {
+					Type:        genai.TypeInteger,
+					Description: fmt.Sprintf("Lines of context around each change (default: %d)", DefaultDiffContext),
+					Minimum:     genai.Ptr(0.0),
+					Maximum:     genai.Ptr(100.0),
+				},
+			},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args gitDiffArgs) (string, error) {
+		if err := checkRef(args.Ref); err != nil {
+			return "", err
+		}
+		paths, err := env.gitPaths(args.Paths)
+		if err != nil {
+			return "", err
+		}
+		cmd := []string{"diff"}
+		if args.Staged {
+			cmd = append(cmd, "--cached")
+		}
+		if args.Ref != "" {
+			cmd = append(cmd, args.Ref)
+		}
+		contextLines := DefaultDiffContext
+		if args.ContextLines != nil {
+			contextLines = *args.ContextLines
+		}
+		diff, err := gitPatch(ctx, env.Workspace.Dir(), cmd, paths, contextLines)
+		if err != nil {
+			return "", err
+		}
+		return marshalOutput(diff)
+	},
+}
+
+func init() {
+	builtins = append(builtins, gitDiffTool)
+}
+
+// gitPatch runs a 'git diff' or 'git show' command and returns the changed
+// files and the size-capped patch. External diff drivers and textconv
+// filters are disabled so the output is always a plain patch.
+func gitPatch(ctx context.Context, dir string, cmd, pathspecs []string, contextLines int) (*GitDiff, error) {
+	args := func(opts ...string) []string {
+		args := append(append([]string{}, cmd...), "--no-ext-diff", "--no-textconv", "-M")
+		args = append(append(args, opts...), "--")
+		return append(args, pathspecs...)
+	}
+
+	stat, _, err := Git(ctx, dir, 1024*1024, args("--numstat", "-z")...)
+	if err != nil {
+		return nil, err
+	}
+	patch, truncated, err := Git(ctx, dir, 0, args(fmt.Sprintf("-U%d", contextLines))...)
+	if err != nil {
+		return nil, err
+	}
+	files := parseNumstat(stat)
+	if files == nil {
+		files = []GitFileStat{}
+	}
+	return &GitDiff{Files: files, Diff: truncateLines(patch, truncated), Truncated: truncated}, nil
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const gitLogDescription = `List commits of the git repository, newest first, with hash, author, date, subject and message body. ` +
+	`Filter by 'paths' to see the history of some files, start from 'ref' instead of the current branch, and limit the number with 'max_count'. ` +
+	`Use git_show to see the changes a commit made.`
+
+const (
+	defaultGitLogCount = 20
+	maxGitLogCount     = 200
+)
+
+type gitLogArgs struct {
+	Ref      string   `json:"ref"`
+	Paths    []string `json:"paths"`
+	MaxCount int      `json:"max_count"`
+}
+
+// GitLog is the output of git_log.
+type GitLog struct {
+	Commits   []GitCommitInfo `json:"commits"`
+	Truncated bool            `json:"truncated,omitempty"`
+}
+
+var gitLogTool = Func[gitLogArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: gitLogDescription,
+		Name:        "git_log",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"ref": {
+					Type:        genai.TypeString,
+					Description: "Commit, branch, tag or range to list, e.g. 'main' or 'v1.2.0..HEAD' (default: HEAD)",
+				},
+				"paths": {
+					Type:        genai.TypeArray,
+					Items:       &genai.Schema{Type: genai.TypeString},
+					Description: "Only list commits that changed these files or directories",
+				},
+				"max_count": {
+					Type:        genai.TypeInteger,
+					Description: fmt.Sprintf("Maximum number of commits to return (default: %d)", defaultGitLogCount),
+					Minimum:     genai.Ptr(1.0),
+					Maximum:     genai.Ptr(float64(maxGitLogCount)),
+				},
+			},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args gitLogArgs) (string, error) {
+		if err := checkRef(args.Ref); err != nil {
+			return "", err
+		}
+		paths, err := env.gitPaths(args.Paths)
+		if err != nil {
+			return "", err
+		}
+		count := args.MaxCount
+		if count <= 0 {
+			count = defaultGitLogCount
+		}
+		cmd := []string{"log", gitLogFormat, fmt.Sprintf("--max-count=%d", min(count, maxGitLogCount))}
+		if args.Ref != "" {
+			cmd = append(cmd, args.Ref)
+		}
+		out, truncated, err := Git(ctx, env.Workspace.Dir(), 0, append(append(cmd, "--"), paths...)...)
+		if err != nil {
+			return "", err
+		}
+		if truncated {
+			// Drop the commit that was cut short.
+			out = out[:strings.LastIndexByte(out, '\x1e')+1]
+		}
+		commits, err := parseLog(out)
+		if err != nil {
+			return "", err
+		}
+		if commits == nil {
+			commits = []GitCommitInfo{}
+		}
+		return marshalOutput(GitLog{Commits: commits, Truncated: truncated})
+	},
+}
+
+func init() {
+	builtins = append(builtins, gitLogTool)
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+const gitShowDescription = `Show a commit of the git repository: its hash, author, date and full message, the files it changed with line counts, ` +
+	`and its unified diff. Limit the diff to some files with 'paths'. Long diffs are truncated; the file list is always complete.`
+
+type gitShowArgs struct {
+	Ref          string   `json:"ref"`
+	Paths        []string `json:"paths"`
+	ContextLines *int     `json:"context_lines"`
+}
+
+// GitShow is the output of git_show.
+type GitShow struct {
+	Commit GitCommitInfo `json:"commit"`
+	GitDiff
+}
+
+var gitShowTool = Func[gitShowArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: gitShowDescription,
+		Name:        "git_show",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"ref": {
+					Type:        genai.TypeString,
+					Description: "The commit, branch or tag to show (default: HEAD)",
+				},
+				"paths": {
+					Type:        genai.TypeArray,
+					Items:       &genai.Schema{Type: genai.TypeString},
+					Description: "Only show the changes to these files or directories",
+				},
+				"context_lines": {
+					Type:        genai.TypeInteger,
+					Description: fmt.Sprintf("Lines of context around each change (default: %d)", DefaultDiffContext),
+					Minimum:     genai.Ptr(0.0),
+					Maximum:     genai.Ptr(100.0),
+				},
+			},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args gitShowArgs) (string, error) {
+		ref := args.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if err := checkRef(ref); err != nil {
+			return "", err
+		}
+		paths, err := env.gitPaths(args.Paths)
+		if err != nil {
+			return "", err
+		}
+		dir := env.Workspace.Dir()
+
+		// Resolve the ref once so the metadata and the diff describe the
+		// same commit.
+		out, _, err := Git(ctx, dir, 0, "show", "--no-patch", gitLogFormat, ref+"^{commit}", "--")
+		if err != nil {
+			return "", err
+		}
+		commits, err := parseLog(out)
+		if err != nil {
+			return "", err
+		}
+		if len(commits) != 1 {
+			return "", errors.New("unexpected git show output")
+		}
+		contextLines := DefaultDiffContext
+		if args.ContextLines != nil {
+			contextLines = *args.ContextLines
+		}
+		diff, err := gitPatch(ctx, dir, []string{"show", "--format=", commits[0].Hash}, paths, contextLines)
+		if err != nil {
+			return "", err
+		}
+		return marshalOutput(GitShow{Commit: commits[0], GitDiff: *diff})
+	},
+}
+
+func init() {
+	builtins = append(builtins, gitShowTool)
+}
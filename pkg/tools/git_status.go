@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const gitStatusDescription = `Show the working tree status of the git repository: the current branch, how far it is ahead of or behind its upstream, ` +
+	`and every changed, untracked or conflicted file with its staged and unstaged state. Paths are relative to the repository root.`
+
+const defaultGitStatusEntries = 500
+
+type gitStatusArgs struct {
+	Paths []string `json:"paths"`
+}
+
+// GitStatus is the output of git_status.
+type GitStatus struct {
+	Branch   string `json:"branch"`
+	Upstream string `json:"upstream,omitempty"`
+	Ahead    int    `json:"ahead,omitempty"`
+	Behind   int    `json:"behind,omitempty"`
+	// Files is empty when the working tree is clean.
+	Files     []GitFileStatus `json:"files"`
+	Truncated bool            `json:"truncated,omitempty"`
+}
+
+// GitFileStatus is the state of one file in the index (Staged) and in the
+// working tree (Unstaged), e.g. "modified", "added" or "untracked". An empty
+// state means the file is unchanged there.
+type GitFileStatus struct {
+	Path     string `json:"path"`
+	OldPath  string `json:"old_path,omitempty"`
+	Staged   string `json:"staged,omitempty"`
+	Unstaged string `json:"unstaged,omitempty"`
+	Conflict bool   `json:"conflict,omitempty"`
+}
+
+var gitStatusTool = Func[gitStatusArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: gitStatusDescription,
+		Name:        "git_status",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"paths": {
+					Type:        genai.TypeArray,
+					Items:       &genai.Schema{Type: genai.TypeString},
+					Description: "Only report these files or directories (default: the whole repository)",
+				},
+			},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args gitStatusArgs) (string, error) {
+		paths, err := env.gitPaths(args.Paths)
+		if err != nil {
+			return "", err
+		}
+		status, err := GitStatusOf(ctx, env.Workspace.Dir(), paths...)
+		if err != nil {
+			return "", err
+		}
+		return marshalOutput(status)
+	},
+}
+
+func init() {
+	builtins = append(builtins, gitStatusTool)
+}
+
+// GitStatusOf returns the status of the repository containing dir, limited to
+// the given pathspecs.
+func GitStatusOf(ctx context.Context, dir string, pathspecs ...string) (*GitStatus, error) {
+	args := append([]string{"status", "--porcelain=v2", "--branch", "-z", "--untracked-files=all", "--"}, pathspecs...)
+	out, truncated, err := Git(ctx, dir, 0, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &GitStatus{Files: []GitFileStatus{}, Truncated: truncated}
+	records := strings.Split(out, "\x00")
+	if truncated {
+		// The last record may be cut short.
+		records = records[:len(records)-1]
+	}
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if len(status.Files) == defaultGitStatusEntries {
+			status.Truncated = true
+			break
+		}
+		switch {
+		case strings.HasPrefix(record, "# branch.head "):
+			status.Branch = strings.TrimPrefix(record, "# branch.head ")
+		case strings.HasPrefix(record, "# branch.upstream "):
+			status.Upstream = strings.TrimPrefix(record, "# branch.upstream ")
+		case strings.HasPrefix(record, "# branch.ab "):
+			var ahead, behind string
+			fmt.Sscan(strings.TrimPrefix(record, "# branch.ab "), &ahead, &behind)
+			status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(ahead, "+"))
+			status.Behind, _ = strconv.Atoi(strings.TrimPrefix(behind, "-"))
+		case strings.HasPrefix(record, "1 "):
+			// 1 XY sub mH mI mW hH hI path
+			if fields := strings.SplitN(record, " ", 9); len(fields) == 9 {
+				status.Files = append(status.Files, changedFile(fields[1], fields[8]))
+			}
+		case strings.HasPrefix(record, "2 "):
+			// 2 XY sub mH mI mW hH hI score path, followed by the old path.
+			if fields := strings.SplitN(record, " ", 10); len(fields) == 10 {
+				file := changedFile(fields[1], fields[9])
+				if i+1 < len(records) {
+					i++
+					file.OldPath = records[i]
+				}
+				status.Files = append(status.Files, file)
+			}
+		case strings.HasPrefix(record, "u "):
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			if fields := strings.SplitN(record, " ", 11); len(fields) == 11 {
+				file := changedFile(fields[1], fields[10])
+				file.Conflict = true
+				status.Files = append(status.Files, file)
+			}
+		case strings.HasPrefix(record, "? "):
+			status.Files = append(status.Files, GitFileStatus{Path: record[2:], Unstaged: "untracked"})
+		}
+	}
+	return status, nil
+}
+
+func changedFile(xy, path string) GitFileStatus {
+	return GitFileStatus{Path: path, Staged: gitStateName(xy[0]), Unstaged: gitStateName(xy[1])}
+}
+
+// gitStateName names a porcelain status letter.
+func gitStateName(c byte) string {
+	switch c {
+	case 'M':
+		return "modified"
+	case 'T':
+		return "type changed"
+	case 'A':
+		return "added"
+	case 'D':
+		return "deleted"
+	case 'R':
+		return "renamed"
+	case 'C':
+		return "copied"
+	case 'U':
+		return "unmerged"
+	default:
+		return ""
+	}
+}
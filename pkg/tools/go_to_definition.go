@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+const goToDefinitionDescription = `Ask the language server (gopls for Go) where the identifier on a line of a file is declared, ` +
+	`e.g. to find the function a call goes to, the type of a variable or a method in another package or the standard library. ` +
+	`Returns the file, line and column of each declaration with its source line; read_file or read_symbol show the rest.`
+
+var goToDefinitionTool = Func[symbolPositionArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: goToDefinitionDescription,
+		Name:        "go_to_definition",
+		Parameters: &genai.Schema{
+			Type:       genai.TypeObject,
+			Properties: symbolPositionProperties(),
+			Required:   []string{"path", "line", "symbol"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args symbolPositionArgs) (string, error) {
+		path, pos, err := env.symbolPosition(args)
+		if err != nil {
+			return "", err
+		}
+		server, err := env.languageServer(path)
+		if err != nil {
+			return "", err
+		}
+		locations, err := server.Definition(ctx, path, pos)
+		if err != nil {
+			return "", err
+		}
+		if len(locations) == 0 {
+			return "", fmt.Errorf("the language server found no declaration of '%s' on line %d", args.Symbol, args.Line)
+		}
+		result := make([]SourceLocation, len(locations))
+		for i, loc := range locations {
+			result[i] = env.sourceLocation(loc.Path, loc.Range.Start)
+		}
+		return marshalOutput(result)
+	},
+}
+
+func init() {
+	builtins = append(builtins, goToDefinitionTool)
+}
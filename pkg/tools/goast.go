@@ -0,0 +1,273 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrUnsupportedLanguage is returned by the code tools for files they cannot
+// parse.
+var ErrUnsupportedLanguage = errors.New("unsupported language: the code tools only understand Go source files (.go)")
+
+// GoSymbol is a declaration found in a Go file.
+type GoSymbol struct {
+	Name string `json:"name"`
+	// Kind is "func", "method", "type", "const" or "var".
+	Kind string `json:"kind"`
+	// Receiver is the receiver type of a method, e.g. "*Tools".
+	Receiver string `json:"receiver,omitempty"`
+	// Signature is the declaration without its body, e.g.
+	// "func New() *Tools" or "type Tools struct".
+	Signature string `json:"signature"`
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	// Doc is the first paragraph of the doc comment.
+	Doc string `json:"doc,omitempty"`
+
+	// start and end include the doc comment, for read_symbol.
+	start, end token.Pos
+}
+
+// QualifiedName is how read_symbol refers to the symbol: the name, or
+// "Type.Method" for methods.
+func (s *GoSymbol) QualifiedName() string {
+	if s.Kind == "method" {
+		recv, _, _ := strings.Cut(strings.TrimPrefix(s.Receiver, "*"), "[")
+		return recv + "." + s.Name
+	}
+	return s.Name
+}
+
+// goFile is a parsed Go file.
+type goFile struct {
+	path string
+	// name is the path shown to the model.
+	name string
+	src  []byte
+	fset *token.FileSet
+	ast  *ast.File
+}
+
+// parseGoFiles parses the Go file at path or, for a directory, the Go files
+// of the package in it. Test files are included only with tests set. name
+// is how path is shown to the model. A file with syntax errors fails the
+// call, since declarations after the error would be missing or misplaced.
+func parseGoFiles(path, name string, tests bool) ([]*goFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			n := entry.Name()
+			if entry.IsDir() || filepath.Ext(n) != ".go" || (!tests && strings.HasSuffix(n, "_test.go")) {
+				continue
+			}
+			paths = append(paths, filepath.Join(path, n))
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no Go files in directory '%s'", name)
+		}
+	} else {
+		if filepath.Ext(path) != ".go" {
+			return nil, fmt.Errorf("'%s': %w", name, ErrUnsupportedLanguage)
+		}
+		paths = []string{path}
+	}
+
+	fset := token.NewFileSet()
+	files := make([]*goFile, 0, len(paths))
+	for _, p := range paths {
+		src, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, p, src, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse '%s': %w", p, err)
+		}
+		display := name
+		if info.IsDir() {
+			display = filepath.ToSlash(filepath.Join(name, filepath.Base(p)))
+		}
+		files = append(files, &goFile{path: p, name: display, src: src, fset: fset, ast: f})
+	}
+	return files, nil
+}
+
+// symbols returns the top-level declarations of the file in source order.
+func (f *goFile) symbols() []*GoSymbol {
+	var symbols []*GoSymbol
+	add := func(name, kind string, node ast.Node, doc *ast.CommentGroup, signature string) *GoSymbol {
+		sym := &GoSymbol{
+			Name:      name,
+			Kind:      kind,
+			Signature: signature,
+			File:      f.name,
+			StartLine: f.fset.Position(node.Pos()).Line,
+			EndLine:   f.fset.Position(node.End()).Line,
+			start:     node.Pos(),
+			end:       node.End(),
+		}
+		if doc != nil {
+			sym.start = doc.Pos()
+			sym.Doc, _, _ = strings.Cut(strings.TrimSpace(doc.Text()), "\n\n")
+		}
+		symbols = append(symbols, sym)
+		return sym
+	}
+
+	for _, decl := range f.ast.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			header := *decl
+			header.Body, header.Doc = nil, nil
+			sym := add(decl.Name.Name, "func", decl, decl.Doc, f.render(&header))
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				sym.Kind = "method"
+				sym.Receiver = f.render(decl.Recv.List[0].Type)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				// A lone spec owns the whole declaration and its doc
+				// comment; grouped specs only their own lines.
+				var node ast.Node = spec
+				doc := decl.Doc
+				if len(decl.Specs) > 1 || decl.Lparen.IsValid() {
+					doc = nil
+				} else {
+					node = decl
+				}
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Doc != nil {
+						doc = spec.Doc
+					}
+					add(spec.Name.Name, "type", node, doc, "type "+spec.Name.Name+f.typeParams(spec.TypeParams)+typeKind(spec))
+				case *ast.ValueSpec:
+					if spec.Doc != nil {
+						doc = spec.Doc
+					}
+					kind := decl.Tok.String()
+					for _, ident := range spec.Names {
+						if ident.Name == "_" {
+							continue
+						}
+						signature := kind + " " + ident.Name
+						if spec.Type != nil {
+							signature += " " + f.render(spec.Type)
+						}
+						add(ident.Name, kind, node, doc, signature)
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// imports returns the import paths of the file.
+func (f *goFile) imports() []string {
+	imports := make([]string, 0, len(f.ast.Imports))
+	for _, spec := range f.ast.Imports {
+		imports = append(imports, strings.Trim(spec.Path.Value, `"`))
+	}
+	return imports
+}
+
+// source returns the lines of the file from start to end, numbered like the
+// output of read_file.
+func (f *goFile) source(start, end token.Pos) string {
+	from, to := f.fset.Position(start), f.fset.Position(end)
+	// Extend to whole lines.
+	lo := bytes.LastIndexByte(f.src[:from.Offset], '\n') + 1
+	hi := to.Offset
+	if i := bytes.IndexByte(f.src[hi:], '\n'); i >= 0 {
+		hi += i
+	} else {
+		hi = len(f.src)
+	}
+	var b strings.Builder
+	for i, line := range strings.Split(string(f.src[lo:hi]), "\n") {
+		fmt.Fprintf(&b, "%6d\t%s\n", from.Line+i, line)
+	}
+	return b.String()
+}
+
+func (f *goFile) render(node any) string {
+	var b bytes.Buffer
+	if err := printer.Fprint(&b, f.fset, node); err != nil {
+		return ""
+	}
+	return b.String()
+}
+
+// typeParams renders the type parameters of a generic type, e.g. "[A any]".
+func (f *goFile) typeParams(params *ast.FieldList) string {
+	if params == nil || len(params.List) == 0 {
+		return ""
+	}
+	list := make([]string, 0, len(params.List))
+	for _, field := range params.List {
+		names := make([]string, len(field.Names))
+		for i, name := range field.Names {
+			names[i] = name.Name
+		}
+		list = append(list, strings.Join(names, ", ")+" "+f.render(field.Type))
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
+
+// typeKind describes the underlying type of a type declaration briefly, as
+// in " struct" or " = other.Type".
+func typeKind(spec *ast.TypeSpec) string {
+	prefix := " "
+	if spec.Assign.IsValid() {
+		prefix = " = "
+	}
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		return prefix + "struct"
+	case *ast.InterfaceType:
+		return prefix + "interface"
+	case *ast.FuncType:
+		return prefix + "func"
+	case *ast.Ident:
+		return prefix + t.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok {
+			return prefix + pkg.Name + "." + t.Sel.Name
+		}
+	case *ast.MapType:
+		return prefix + "map"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return prefix + "slice"
+		}
+		return prefix + "array"
+	case *ast.ChanType:
+		return prefix + "chan"
+	}
+	return ""
+}
+
+// sortedUnique sorts the strings and removes duplicates.
+func sortedUnique(list []string) []string {
+	slices.Sort(list)
+	return slices.Compact(list)
+}
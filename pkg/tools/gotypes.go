@@ -0,0 +1,330 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// goLoadMode loads everything needed to resolve identifiers to objects.
+// Dependencies are type-checked from source rather than from export data,
+// which only matches the toolchain it was built with.
+const goLoadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+	packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo
+
+// goProgram is the type-checked Go code of a workspace: every package below
+// its root, including test packages.
+type goProgram struct {
+	dir  string
+	fset *token.FileSet
+	pkgs []*packages.Package
+}
+
+// GoReference is an identifier referring to a Go object.
+type GoReference struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	// Text is the source line, trimmed.
+	Text string `json:"text"`
+	// Declaration marks the identifier declaring the object.
+	Declaration bool `json:"declaration,omitempty"`
+
+	filename string
+	offset   int
+}
+
+// loadGoProgram type-checks the packages below dir. overlay replaces the
+// contents of files, keyed by absolute path, without writing them.
+func loadGoProgram(ctx context.Context, dir string, overlay map[string][]byte) (*goProgram, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	prog := &goProgram{dir: dir, fset: token.NewFileSet()}
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    goLoadMode,
+		Dir:     dir,
+		Fset:    prog.fset,
+		Tests:   true,
+		Overlay: overlay,
+		// Only the declarations of dependencies outside dir matter; skipping
+		// their function bodies makes type-checking them cheap. Main packages
+		// outside dir are the generated test mains, which are checked whole.
+		ParseFile: func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+			f, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.SkipObjectResolution)
+			if f != nil && !within(dir, filename) && f.Name.Name != "main" {
+				for _, decl := range f.Decls {
+					if fn, ok := decl.(*ast.FuncDecl); ok {
+						fn.Body = nil
+					}
+				}
+			}
+			return f, err
+		},
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Go packages: %w", err)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no Go packages found in %s", dir)
+	}
+	prog.pkgs = pkgs
+	return prog, nil
+}
+
+// errors returns the errors found while loading, each once.
+func (prog *goProgram) errors() []packages.Error {
+	var errs []packages.Error
+	seen := make(map[string]bool)
+	packages.Visit(prog.pkgs, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			if !seen[err.Error()] {
+				seen[err.Error()] = true
+				errs = append(errs, err)
+			}
+		}
+	})
+	return errs
+}
+
+// errorsAffecting returns the errors of the packages that refer to obj or,
+// when it is exported, import its package. Their type information may be
+// incomplete, so references to obj in them could be missed.
+func (prog *goProgram) errorsAffecting(obj types.Object, refs []*GoReference) []string {
+	files := make(map[string]bool)
+	for _, ref := range refs {
+		files[ref.filename] = true
+	}
+	var errs []string
+	seen := make(map[string]bool)
+	for _, pkg := range prog.pkgs {
+		_, imports := pkg.Imports[obj.Pkg().Path()]
+		affected := imports && obj.Exported() || pkg.PkgPath == obj.Pkg().Path() ||
+			slices.ContainsFunc(pkg.CompiledGoFiles, func(name string) bool { return files[name] })
+		if !affected {
+			continue
+		}
+		for _, err := range pkg.Errors {
+			if msg := err.Error(); !seen[msg] {
+				seen[msg] = true
+				errs = append(errs, msg)
+			}
+		}
+	}
+	if len(errs) > 10 {
+		errs = append(errs[:10], "...")
+	}
+	return errs
+}
+
+// objectDefinedAt returns the object declared by the identifier at offset in
+// the file.
+func (prog *goProgram) objectDefinedAt(filename string, offset int) types.Object {
+	for _, pkg := range prog.pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for ident, obj := range pkg.TypesInfo.Defs {
+			if pos := prog.fset.Position(ident.Pos()); obj != nil && pos.Filename == filename && pos.Offset == offset {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+// objectAt finds the object a find_references or rename_symbol call names.
+// symbol is a package-level name, "Type.Method" or "Type.Field" in the
+// package of the file at path; with line set it is instead an identifier on
+// that line of the file, which may be a local variable.
+func (prog *goProgram) objectAt(path, symbol string, line int) (types.Object, error) {
+	pkg, file := prog.fileOf(path)
+	if file == nil {
+		return nil, fmt.Errorf("'%s' is not part of a Go package below %s", path, prog.dir)
+	}
+
+	if line > 0 {
+		var found types.Object
+		ast.Inspect(file, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok || found != nil || ident.Name != symbol || prog.fset.Position(ident.Pos()).Line != line {
+				return found == nil
+			}
+			if obj := pkg.TypesInfo.ObjectOf(ident); obj != nil {
+				found = obj
+			}
+			return false
+		})
+		if found == nil {
+			return nil, fmt.Errorf("no identifier '%s' on line %d of '%s'", symbol, line, path)
+		}
+		return found, nil
+	}
+
+	name, member, isMember := strings.Cut(strings.TrimPrefix(symbol, "*"), ".")
+	obj := pkg.Types.Scope().Lookup(name)
+	if obj == nil {
+		return nil, fmt.Errorf("'%s' is not declared in package %s; pass 'line' to name a local identifier", name, pkg.Types.Name())
+	}
+	if !isMember {
+		return obj, nil
+	}
+	if _, ok := obj.(*types.TypeName); !ok {
+		return nil, fmt.Errorf("'%s' is not a type", name)
+	}
+	memberObj, _, _ := types.LookupFieldOrMethod(obj.Type(), true, pkg.Types, member)
+	if memberObj == nil {
+		return nil, fmt.Errorf("type %s has no field or method '%s'", name, member)
+	}
+	return memberObj, nil
+}
+
+// fileOf returns the package and syntax of the file at path, preferring the
+// package variant without tests.
+func (prog *goProgram) fileOf(path string) (*packages.Package, *ast.File) {
+	var bestPkg *packages.Package
+	var bestFile *ast.File
+	for _, pkg := range prog.pkgs {
+		for i, name := range pkg.CompiledGoFiles {
+			if name != path || i >= len(pkg.Syntax) {
+				continue
+			}
+			if bestPkg == nil || len(pkg.ID) < len(bestPkg.ID) {
+				bestPkg, bestFile = pkg, pkg.Syntax[i]
+			}
+		}
+	}
+	return bestPkg, bestFile
+}
+
+// references returns every identifier referring to obj, in file order. The
+// same object is type-checked once per package variant, so objects are
+// matched by the position of their declaration.
+func (prog *goProgram) references(obj types.Object) []*GoReference {
+	target := prog.fset.Position(obj.Pos())
+	same := func(other types.Object) bool {
+		if other == nil || other.Name() != obj.Name() {
+			return false
+		}
+		pos := prog.fset.Position(other.Pos())
+		return pos.Filename == target.Filename && pos.Offset == target.Offset
+	}
+	// An embedded field is implicitly named after its type, so selecting
+	// the field refers to the type name too.
+	_, isType := obj.(*types.TypeName)
+	matches := func(other types.Object) bool {
+		if v, ok := other.(*types.Var); ok && isType && v.Embedded() {
+			return same(typeNameOf(v.Type()))
+		}
+		return same(other)
+	}
+
+	seen := make(map[string]bool)
+	var refs []*GoReference
+	add := func(ident *ast.Ident, decl bool) {
+		pos := prog.fset.Position(ident.Pos())
+		key := fmt.Sprintf("%s:%d", pos.Filename, pos.Offset)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		refs = append(refs, &GoReference{
+			Line:        pos.Line,
+			Column:      pos.Column,
+			Declaration: decl,
+			filename:    pos.Filename,
+			offset:      pos.Offset,
+		})
+	}
+	for _, pkg := range prog.pkgs {
+		if pkg.TypesInfo == nil {
+			continue
+		}
+		for ident, def := range pkg.TypesInfo.Defs {
+			if matches(def) {
+				add(ident, same(def))
+			}
+		}
+		for ident, use := range pkg.TypesInfo.Uses {
+			if matches(use) {
+				add(ident, false)
+			}
+		}
+	}
+	slices.SortFunc(refs, func(a, b *GoReference) int {
+		if c := strings.Compare(a.filename, b.filename); c != 0 {
+			return c
+		}
+		return a.offset - b.offset
+	})
+
+	lines := make(map[string][]string)
+	for _, ref := range refs {
+		ref.File = prog.relative(ref.filename)
+		if _, ok := lines[ref.filename]; !ok {
+			data, _ := os.ReadFile(ref.filename)
+			lines[ref.filename] = strings.Split(string(data), "\n")
+		}
+		if l := lines[ref.filename]; ref.Line-1 < len(l) {
+			ref.Text = truncateLine(strings.TrimSpace(l[ref.Line-1]))
+		}
+	}
+	return refs
+}
+
+// relative returns path relative to the program directory when it is inside.
+func (prog *goProgram) relative(path string) string {
+	if rel, err := filepath.Rel(prog.dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// typeNameOf returns the named type behind t, dereferencing a pointer.
+func typeNameOf(t types.Type) types.Object {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj()
+	}
+	return nil
+}
+
+// describeObject names the kind of obj for the tool output.
+func describeObject(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.TypeName:
+		return "type"
+	case *types.Const:
+		return "const"
+	case *types.Var:
+		if obj.IsField() {
+			return "field"
+		}
+		return "var"
+	case *types.PkgName:
+		return "package name"
+	case *types.Label:
+		return "label"
+	default:
+		return "object"
+	}
+}
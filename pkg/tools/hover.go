@@ -0,0 +1,46 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+const hoverDescription = `Ask the language server (gopls for Go) about the identifier on a line of a file: ` +
+	`returns what an editor shows on hover, usually its declaration with the resolved type and its documentation. ` +
+	`Useful to learn the type of a variable or the signature and docs of a function without reading its source.`
+
+var hoverTool = Func[symbolPositionArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: hoverDescription,
+		Name:        "hover",
+		Parameters: &genai.Schema{
+			Type:       genai.TypeObject,
+			Properties: symbolPositionProperties(),
+			Required:   []string{"path", "line", "symbol"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args symbolPositionArgs) (string, error) {
+		path, pos, err := env.symbolPosition(args)
+		if err != nil {
+			return "", err
+		}
+		server, err := env.languageServer(path)
+		if err != nil {
+			return "", err
+		}
+		text, err := server.Hover(ctx, path, pos)
+		if err != nil {
+			return "", err
+		}
+		if text == "" {
+			return fmt.Sprintf("The language server has no information about '%s' on line %d", args.Symbol, args.Line), nil
+		}
+		return text, nil
+	},
+}
+
+func init() {
+	builtins = append(builtins, hoverTool)
+}
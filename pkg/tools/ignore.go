@@ -0,0 +1,286 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ProjectIgnoreFile is read from the workspace root in addition to the git
+// ignore files. It lists paths the tools should skip even though git tracks
+// them, such as generated code or fixtures.
+const ProjectIgnoreFile = ".cooderignore"
+
+// Ignore matches workspace paths against the rules of the .gitignore files
+// found while walking the tree, of .git/info/exclude and of the project
+// ignore file. It is not safe for concurrent use.
+type Ignore struct {
+	root string
+	// global rules apply to the whole tree with the lowest precedence.
+	global []*ignoreList
+	// lists caches the .gitignore of every visited directory by its slash
+	// separated path relative to root; "" is the root itself.
+	lists map[string]*ignoreList
+}
+
+type ignoreList struct {
+	// base is the directory the patterns are relative to.
+	base  string
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// NewIgnore returns the ignore rules of the tree rooted at root.
+func NewIgnore(root string) *Ignore {
+	ig := &Ignore{root: root, lists: make(map[string]*ignoreList)}
+	for _, name := range []string{filepath.Join(".git", "info", "exclude"), ProjectIgnoreFile} {
+		if list := readIgnoreFile(filepath.Join(root, name), ""); list != nil {
+			ig.global = append(ig.global, list)
+		}
+	}
+	return ig
+}
+
+// newWorkspaceIgnore returns the ignore rules for walking dir. The rules start
+// at the workspace root when dir is inside it, so .gitignore files above dir
+// still apply, and at dir itself otherwise.
+func newWorkspaceIgnore(ws *Workspace, dir string) *Ignore {
+	root, err := filepath.Abs(ws.Dir())
+	if err != nil || !within(root, dir) {
+		root = dir
+	}
+	return NewIgnore(root)
+}
+
+// Match reports whether the path, relative to the root and slash separated,
+// is ignored. Rules in deeper .gitignore files override shallower ones and,
+// within a file, the last matching rule wins.
+func (ig *Ignore) Match(rel string, isDir bool) bool {
+	ignored := false
+	apply := func(list *ignoreList) {
+		if list == nil {
+			return
+		}
+		name := rel
+		if list.base != "" {
+			name = strings.TrimPrefix(rel, list.base+"/")
+		}
+		for _, rule := range list.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.re.MatchString(name) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	for _, list := range ig.global {
+		apply(list)
+	}
+	apply(ig.load(""))
+	for i := range len(rel) {
+		if rel[i] == '/' {
+			apply(ig.load(rel[:i]))
+		}
+	}
+	return ignored
+}
+
+// load returns the rules of the .gitignore in dir, reading it once.
+func (ig *Ignore) load(dir string) *ignoreList {
+	if list, ok := ig.lists[dir]; ok {
+		return list
+	}
+	list := readIgnoreFile(filepath.Join(ig.root, filepath.FromSlash(dir), ".gitignore"), dir)
+	ig.lists[dir] = list
+	return list
+}
+
+// Walk calls fn for every file and directory below dir that is not ignored,
+// in lexical order. The .git directory is always skipped. fn receives the
+// absolute path and the slash separated path relative to the root; returning
+// fs.SkipDir or fs.SkipAll behaves as for filepath.WalkDir. Entries that
+// cannot be read are skipped.
+func (ig *Ignore) Walk(ctx context.Context, dir string, fn func(path, rel string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(ig.root, p)
+		if err != nil {
+			return fmt.Errorf("error getting relative path: %w", err)
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() && d.Name() == ".git" {
+			return fs.SkipDir
+		}
+		if ig.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		return fn(p, rel, d)
+	})
+}
+
+// readIgnoreFile parses an ignore file, returning nil when it does not exist
+// or has no rules.
+func readIgnoreFile(name, base string) *ignoreList {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil
+	}
+	list := &ignoreList{base: base}
+	scn := bufio.NewScanner(bytes.NewReader(data))
+	for scn.Scan() {
+		if rule, ok := parseIgnoreRule(scn.Text()); ok {
+			list.rules = append(list.rules, rule)
+		}
+	}
+	if len(list.rules) == 0 {
+		return nil
+	}
+	return list
+}
+
+// parseIgnoreRule parses one line of a .gitignore file.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A pattern without a slash matches at any depth; otherwise it is
+	// relative to the directory of the ignore file.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expr, err := globRegexp(line)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// Glob matches slash separated paths against a gitignore style glob. A glob
+// without a slash, like "*.go", matches the base name at any depth; "**"
+// matches any number of directories, as in "pkg/**/*_test.go".
+type Glob struct {
+	re *regexp.Regexp
+}
+
+// CompileGlob parses a glob pattern.
+func CompileGlob(pattern string) (*Glob, error) {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "/")
+	expr, err := globRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob '%s': %w", pattern, err)
+	}
+	if !strings.Contains(pattern, "/") {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob '%s': %w", pattern, err)
+	}
+	return &Glob{re: re}, nil
+}
+
+// Match reports whether the slash separated path matches the glob.
+func (g *Glob) Match(rel string) bool {
+	return g.re.MatchString(rel)
+}
+
+// globRegexp translates a glob into a regular expression matching whole
+// slash separated paths.
+func globRegexp(glob string) (string, error) {
+	if _, err := path.Match(strings.ReplaceAll(glob, "**", "*"), ""); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			// Zero or more leading directories.
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", errors.New("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,204 @@
+package tools
+
+// diffOp is one step of an edit script: Op is ' ' for a line kept from both
+// sides, '-' for a line only in the old side and '+' for one only in the new.
+type diffOp struct {
+	Op   byte
+	Text string
+}
+
+// maxMyersCost bounds the edit distance searched by myers. Past it the
+// remaining region is reported as replaced wholesale, which keeps memory
+// bounded on unrelated inputs.
+const maxMyersCost = 2000
+
+// diffSeq computes a minimal-looking edit script turning a into b. Lines that
+// occur exactly once on both sides are matched first, as in patience diff,
+// which keeps unrelated blocks from being interleaved; the regions between
+// those anchors are diffed with Myers' algorithm.
+func diffSeq(a, b []string) []diffOp {
+	var ops []diffOp
+	patience(a, b, &ops)
+	return ops
+}
+
+func patience(a, b []string, ops *[]diffOp) {
+	// Common prefix and suffix.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	for _, line := range a[:pre] {
+		*ops = append(*ops, diffOp{' ', line})
+	}
+	suffix := a[len(a)-suf:]
+	defer appendOps(ops, ' ', suffix)
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	switch {
+	case len(a) == 0:
+		appendOps(ops, '+', b)
+		return
+	case len(b) == 0:
+		appendOps(ops, '-', a)
+		return
+	}
+
+	anchors := uniqueAnchors(a, b)
+	if len(anchors) == 0 {
+		myers(a, b, ops)
+		return
+	}
+	ai, bi := 0, 0
+	for _, anchor := range anchors {
+		patience(a[ai:anchor[0]], b[bi:anchor[1]], ops)
+		*ops = append(*ops, diffOp{' ', a[anchor[0]]})
+		ai, bi = anchor[0]+1, anchor[1]+1
+	}
+	patience(a[ai:], b[bi:], ops)
+}
+
+// uniqueAnchors returns the longest increasing sequence of index pairs of
+// lines that occur exactly once in both a and b.
+func uniqueAnchors(a, b []string) [][2]int {
+	type count struct{ a, b, ai, bi int }
+	counts := make(map[string]*count)
+	for i, line := range a {
+		c := counts[line]
+		if c == nil {
+			c = &count{}
+			counts[line] = c
+		}
+		c.a++
+		c.ai = i
+	}
+	for i, line := range b {
+		if c := counts[line]; c != nil {
+			c.b++
+			c.bi = i
+		}
+	}
+
+	// Pairs in a order; find the longest run increasing in b (patience sort).
+	var pairs [][2]int
+	for i, line := range a {
+		if c := counts[line]; c.a == 1 && c.b == 1 {
+			pairs = append(pairs, [2]int{i, c.bi})
+		}
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	var piles []int // index into pairs of the top of each pile
+	prev := make([]int, len(pairs))
+	for i, pair := range pairs {
+		lo, hi := 0, len(piles)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if pairs[piles[mid]][1] < pair[1] {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = piles[lo-1]
+		}
+		if lo == len(piles) {
+			piles = append(piles, i)
+		} else {
+			piles[lo] = i
+		}
+	}
+	anchors := make([][2]int, len(piles))
+	for i, k := len(piles)-1, piles[len(piles)-1]; i >= 0; i, k = i-1, prev[k] {
+		anchors[i] = pairs[k]
+	}
+	return anchors
+}
+
+// myers appends the shortest edit script from a to b, following "An O(ND)
+// Difference Algorithm and Its Variations" (Myers, 1986).
+func myers(a, b []string, ops *[]diffOp) {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxMyersCost)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	found := -1
+	for d := 0; d <= limit && found < 0; d++ {
+		// Only diagonals -d-1..d+1 are read when backtracking from round d.
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+	if found < 0 {
+		appendOps(ops, '-', a)
+		appendOps(ops, '+', b)
+		return
+	}
+
+	// Walk the trace back from (n, m) to recover the script in reverse.
+	var rev []diffOp
+	x, y := n, m
+	for d := found; d > 0; d-- {
+		vd, base := trace[d], d+1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && vd[base+k-1] < vd[base+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := vd[base+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, diffOp{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, diffOp{'+', b[y]})
+		} else {
+			x--
+			rev = append(rev, diffOp{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, diffOp{' ', a[x]})
+	}
+	for i := len(rev) - 1; i >= 0; i-- {
+		*ops = append(*ops, rev[i])
+	}
+}
+
+func appendOps(ops *[]diffOp, op byte, lines []string) {
+	for _, line := range lines {
+		*ops = append(*ops, diffOp{op, line})
+	}
+}
@@ -1,65 +1,138 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/fs"
-	"os"
 	"path/filepath"
+	"strings"
 
 	"google.golang.org/genai"
 )
 
-const listfilesDescription = "List files and directories at a given path. If no path is provided, lists files in the current directory."
+const listfilesDescription = "List files and directories at a given path. If no path is provided, lists files in the current directory. " +
+	"Files ignored by .gitignore, .git/info/exclude or " + ProjectIgnoreFile + " are skipped. " +
+	"If the result is truncated, list a subdirectory or narrow it with depth or glob."
 
-var listFilesTool = &genai.Tool{
-	FunctionDeclarations: []*genai.FunctionDeclaration{
-		{
-			Description: listfilesDescription,
-			Name:        "list_files",
-			Parameters: &genai.Schema{
-				Type: genai.TypeObject,
-				Properties: map[string]*genai.Schema{
-					"path": {
-						Type: genai.TypeString,
-					},
+const defaultListEntries = 500
+
+type listFilesArgs struct {
+	Path       string `json:"path"`
+	Depth      int    `json:"depth"`
+	Glob       string `json:"glob"`
+	MaxEntries int    `json:"max_entries"`
+}
+
+// FileEntry is a file or directory reported by list_files. Directory paths
+// end with a separator.
+type FileEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// FileList is the output of list_files.
+type FileList struct {
+	Entries   []FileEntry `json:"entries"`
+	Truncated bool        `json:"truncated"`
+}
+
+var listFilesTool = Func[listFilesArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: listfilesDescription,
+		Name:        "list_files",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"path": {
+					Type: genai.TypeString,
+				},
+				"depth": {
+					Type:        genai.TypeInteger,
+					Description: "Maximum depth to descend, 1 lists only the direct children (default: unlimited)",
+					Minimum:     genai.Ptr(0.0),
+				},
+				"glob": {
+					Type:        genai.TypeString,
+					Description: "Only list files matching this glob, e.g. '*.go' or 'cmd/**/*.go'; directories are omitted. A glob with a slash is matched against the path relative to 'path', like the listed entries",
+				},
+				"max_entries": {
+					Type:        genai.TypeInteger,
+					Description: fmt.Sprintf("Maximum number of entries to return (default: %d)", defaultListEntries),
+					Minimum:     genai.Ptr(1.0),
 				},
 			},
 		},
 	},
+	Run: func(ctx context.Context, env *Env, args listFilesArgs) (string, error) {
+		path, err := env.Workspace.Resolve(args.Path, false)
+		if err != nil {
+			return "", err
+		}
+		return ListFiles(ctx, env.Workspace, path, args)
+	},
 }
 
-func ListFiles(path string) (string, error) {
-	if path == "" {
-		path = "."
-	}
+func init() {
+	builtins = append(builtins, listFilesTool)
+}
 
-	var files []string
-	err := filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return fmt.Errorf("error walking directory: %w", err)
+// ListFiles lists the entries below path that are not ignored, in lexical
+// order, as a JSON FileList.
+func ListFiles(ctx context.Context, ws *Workspace, path string, args listFilesArgs) (string, error) {
+	var glob *Glob
+	if args.Glob != "" {
+		var err error
+		if glob, err = CompileGlob(args.Glob); err != nil {
+			return "", err
 		}
+	}
+	maxEntries := args.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultListEntries
+	}
 
+	list := FileList{Entries: []FileEntry{}}
+	ig := newWorkspaceIgnore(ws, path)
+	err := ig.Walk(ctx, path, func(filePath, _ string, d fs.DirEntry) error {
 		baseName, err := filepath.Rel(path, filePath)
 		if err != nil {
 			return fmt.Errorf("error getting relative path: %w", err)
 		}
+		// Directories at the maximum depth are listed but not entered.
+		var next error
+		if d.IsDir() && args.Depth > 0 && strings.Count(filepath.ToSlash(baseName), "/")+1 >= args.Depth {
+			next = fs.SkipDir
+		}
 
-		if baseName != "." {
-			if d.IsDir() {
-				files = append(files, baseName+string(os.PathSeparator))
-			} else {
-				files = append(files, baseName)
+		entry := FileEntry{Path: baseName}
+		if d.IsDir() {
+			if glob != nil {
+				return next
+			}
+			entry.Path += string(filepath.Separator)
+		} else {
+			// Globs are relative to the listed directory, as in Search.
+			if glob != nil && !glob.Match(filepath.ToSlash(baseName)) {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				entry.Size = info.Size()
 			}
 		}
-		return nil
-	})
 
+		if len(list.Entries) == maxEntries {
+			list.Truncated = true
+			return fs.SkipAll
+		}
+		list.Entries = append(list.Entries, entry)
+		return next
+	})
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("error walking directory: %w", err)
 	}
 
-	result, err := json.Marshal(files)
+	result, err := json.Marshal(list)
 	if err != nil {
 		return "", fmt.Errorf("error marshaling JSON: %w", err)
 	}
@@ -0,0 +1,270 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"cooder-assist/pkg/lsp"
+
+	"google.golang.org/genai"
+)
+
+// maxDiagnostics caps the diagnostics returned by the diagnostics tool and
+// appended to edit responses.
+const maxDiagnostics = 200
+
+// symbolPositionArgs name an identifier on a line of a file, for the tools
+// asking the language server about it.
+type symbolPositionArgs struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Symbol string `json:"symbol"`
+	Column int    `json:"column"`
+}
+
+// symbolPositionProperties are the parameters of symbolPositionArgs, shared
+// by go_to_definition and hover.
+func symbolPositionProperties() map[string]*genai.Schema {
+	return map[string]*genai.Schema{
+		"path": {
+			Type:        genai.TypeString,
+			Description: "The file containing the identifier",
+		},
+		"line": {
+			Type:        genai.TypeInteger,
+			Description: "The line the identifier is on, counting from 1",
+			Minimum:     genai.Ptr(1.0),
+		},
+		"symbol": {
+			Type:        genai.TypeString,
+			Description: "The identifier as written on the line, e.g. 'NewWorkspace' or 'env.Workspace' for the selected 'Workspace'",
+		},
+		"column": {
+			Type:        genai.TypeInteger,
+			Description: "The column of the identifier, counting bytes from 1, when it appears more than once on the line",
+			Minimum:     genai.Ptr(1.0),
+		},
+	}
+}
+
+// SourceLocation is a place in a file reported by the language server.
+type SourceLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	// Text is the source line, trimmed.
+	Text string `json:"text,omitempty"`
+}
+
+// FileDiagnostic is a problem the language server reports in a file.
+type FileDiagnostic struct {
+	SourceLocation
+	Severity string `json:"severity"`
+	Source   string `json:"source,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (d *FileDiagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+}
+
+// languageServer returns the language server to ask about the file at path.
+func (env *Env) languageServer(path string) (*lsp.Client, error) {
+	if env.LSP == nil {
+		return nil, errors.New("no language server is configured")
+	}
+	if !env.LSP.Handles(path) {
+		return nil, fmt.Errorf("the language server '%s' does not handle '%s'", env.LSP.Command(), filepath.Base(path))
+	}
+	return env.LSP, nil
+}
+
+// symbolPosition resolves the arguments to the file and the position of the
+// identifier in it. The position is at the last identifier of a selector
+// such as 'env.Workspace'.
+func (env *Env) symbolPosition(args symbolPositionArgs) (string, lsp.Position, error) {
+	path, err := env.Workspace.Resolve(args.Path, false)
+	if err != nil {
+		return "", lsp.Position{}, err
+	}
+	text, err := lineOf(path, args.Line)
+	if err != nil {
+		return "", lsp.Position{}, fmt.Errorf("failed to read line %d of '%s': %w", args.Line, args.Path, err)
+	}
+	symbol := strings.TrimSpace(args.Symbol)
+	if symbol == "" {
+		return "", lsp.Position{}, errors.New("Invalid argument: 'symbol' is empty")
+	}
+
+	var columns []string
+	for _, at := range matchOffsets(text, symbol) {
+		end := at + len(symbol)
+		if isIdentByteAt(text, at-1) || isIdentByteAt(text, end) {
+			continue
+		}
+		columns = append(columns, fmt.Sprint(at+1))
+		if args.Column > 0 && (args.Column-1 < at || args.Column-1 >= end) {
+			continue
+		}
+		col := at + strings.LastIndex(symbol, ".") + 1
+		return path, lsp.Position{Line: args.Line - 1, Character: lsp.Character(text, col)}, nil
+	}
+	if len(columns) > 0 {
+		return "", lsp.Position{}, fmt.Errorf("'%s' is not at column %d of line %d; it starts at column %s", symbol, args.Column, args.Line, strings.Join(columns, ", "))
+	}
+	return "", lsp.Position{}, fmt.Errorf("'%s' is not on line %d of '%s': %s", symbol, args.Line, args.Path, truncateLine(strings.TrimSpace(text)))
+}
+
+// isIdentByteAt reports whether text has an identifier character at i, which
+// may be out of range.
+func isIdentByteAt(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return false
+	}
+	c := text[i]
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// lineOf returns the 1-based line n of the file at path.
+func lineOf(path string, n int) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(data), "\n")
+	if n < 1 || n > len(lines) {
+		return "", fmt.Errorf("the file has %d lines", len(lines))
+	}
+	return strings.TrimSuffix(lines[n-1], "\r"), nil
+}
+
+// sourceLocation converts a position from the language server to a 1-based
+// line and byte column, with the path relative to the workspace when inside.
+func (env *Env) sourceLocation(path string, pos lsp.Position) SourceLocation {
+	loc := SourceLocation{File: env.displayPath(path), Line: pos.Line + 1, Column: pos.Character + 1}
+	if text, err := lineOf(path, loc.Line); err == nil {
+		loc.Column = lsp.ByteOffset(text, pos.Character) + 1
+		loc.Text = truncateLine(strings.TrimSpace(text))
+	}
+	return loc
+}
+
+// displayPath returns path relative to the workspace root when it is inside.
+func (env *Env) displayPath(path string) string {
+	root, err := filepath.Abs(env.Workspace.Dir())
+	if err != nil || !within(root, path) {
+		return path
+	}
+	rel, _ := filepath.Rel(root, path)
+	return filepath.ToSlash(rel)
+}
+
+// diagnostics asks the language server for the diagnostics of the files at
+// paths. They are returned in full, followed by the errors it reports in
+// other files, which edits to paths may have caused.
+func (env *Env) diagnostics(ctx context.Context, paths []string) ([]*FileDiagnostic, []string, error) {
+	report, err := env.LSP.Diagnostics(ctx, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	var requested, others []*FileDiagnostic
+	for path, diags := range report.Files {
+		isRequested := slices.Contains(paths, path)
+		for _, d := range diags {
+			if !isRequested && d.Severity != lsp.SeverityError {
+				continue
+			}
+			fd := &FileDiagnostic{
+				SourceLocation: SourceLocation{File: env.displayPath(path), Line: d.Range.Start.Line + 1, Column: d.Range.Start.Character + 1},
+				Severity:       d.Severity.String(),
+				Source:         d.Source,
+				Code:           d.CodeString(),
+				Message:        d.Message,
+			}
+			if text, err := lineOf(path, fd.Line); err == nil {
+				fd.Column = lsp.ByteOffset(text, d.Range.Start.Character) + 1
+			}
+			if isRequested {
+				requested = append(requested, fd)
+			} else {
+				others = append(others, fd)
+			}
+		}
+	}
+	sortDiagnostics(requested)
+	sortDiagnostics(others)
+
+	pending := make([]string, len(report.Pending))
+	for i, path := range report.Pending {
+		pending[i] = env.displayPath(path)
+	}
+	return append(requested, others...), pending, nil
+}
+
+func sortDiagnostics(diags []*FileDiagnostic) {
+	slices.SortFunc(diags, func(a, b *FileDiagnostic) int {
+		if c := strings.Compare(a.File, b.File); c != 0 {
+			return c
+		}
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+}
+
+// editDiagnostics returns the errors and warnings the language server
+// reports after an edit tool wrote the files at paths, to append to its
+// output. It is empty unless AppendDiagnostics is set.
+func (env *Env) editDiagnostics(ctx context.Context, paths []string) string {
+	if !env.AppendDiagnostics || env.LSP == nil {
+		return ""
+	}
+	var handled []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil && env.LSP.Handles(path) {
+			handled = append(handled, path)
+		}
+	}
+	if len(handled) == 0 {
+		return ""
+	}
+	diags, pending, err := env.diagnostics(ctx, handled)
+	if err != nil {
+		return fmt.Sprintf("\n\nDiagnostics are unavailable: %v", err)
+	}
+
+	var lines []string
+	for _, d := range diags {
+		if d.Severity == "error" || d.Severity == "warning" {
+			lines = append(lines, d.String())
+		}
+	}
+	var b strings.Builder
+	switch {
+	case len(lines) == 0:
+		b.WriteString("\n\nThe language server reports no errors or warnings.")
+	case len(lines) > maxDiagnostics:
+		fmt.Fprintf(&b, "\n\nDiagnostics (first %d of %d):\n%s", maxDiagnostics, len(lines), strings.Join(lines[:maxDiagnostics], "\n"))
+	default:
+		fmt.Fprintf(&b, "\n\nDiagnostics:\n%s", strings.Join(lines, "\n"))
+	}
+	if len(pending) > 0 {
+		fmt.Fprintf(&b, "\n(The language server has not finished checking %s; call diagnostics again for current results.)", strings.Join(pending, ", "))
+	}
+	return b.String()
+}
+
+// syncLanguageServer tells the language server that tools wrote the files
+// at paths.
+func (env *Env) syncLanguageServer(paths []string) {
+	if env.LSP != nil {
+		env.LSP.Sync(paths)
+	}
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cooder-assist/pkg/lsp"
+)
+
+func TestSymbolPosition(t *testing.T) {
+	root := t.TempDir()
+	src := "package demo\n" +
+		"func Run(env *Env) { env.Workspace.Resolve(env.Workspace) }\n" +
+		"var s = \"é🙂\" + Name\n"
+	if err := os.WriteFile(filepath.Join(root, "demo.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := NewWorkspace(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &Env{Workspace: ws}
+
+	tests := []struct {
+		name    string
+		args    symbolPositionArgs
+		want    lsp.Position
+		wantErr string
+	}{
+		{name: "identifier", args: symbolPositionArgs{Line: 2, Symbol: "Run"}, want: lsp.Position{Line: 1, Character: 5}},
+		{name: "selector points at the last identifier", args: symbolPositionArgs{Line: 2, Symbol: "env.Workspace"}, want: lsp.Position{Line: 1, Character: 25}},
+		{name: "column picks an occurrence", args: symbolPositionArgs{Line: 2, Symbol: "env.Workspace", Column: 50}, want: lsp.Position{Line: 1, Character: 47}},
+		{name: "whole identifiers only", args: symbolPositionArgs{Line: 2, Symbol: "env"}, want: lsp.Position{Line: 1, Character: 9}},
+		{name: "UTF-16 character offset", args: symbolPositionArgs{Line: 3, Symbol: "Name"}, want: lsp.Position{Line: 2, Character: 16}},
+		{name: "part of an identifier", args: symbolPositionArgs{Line: 2, Symbol: "Work"}, wantErr: "'Work' is not on line 2"},
+		{name: "wrong column", args: symbolPositionArgs{Line: 2, Symbol: "env.Workspace", Column: 1}, wantErr: "it starts at column 22, 44"},
+		{name: "empty symbol", args: symbolPositionArgs{Line: 2, Symbol: " "}, wantErr: "'symbol' is empty"},
+		{name: "line out of range", args: symbolPositionArgs{Line: 9, Symbol: "Run"}, wantErr: "failed to read line 9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.Path = "demo.go"
+			path, got, err := env.symbolPosition(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != filepath.Join(ws.Root, "demo.go") {
+				t.Errorf("path = %q", path)
+			}
+			if got != tt.want {
+				t.Errorf("position = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+const multiEditDescription = `Apply several edits to one file in a single step. The edits are applied in order, each to the result of the previous one, ` +
+	`with the same rules as edit_file: every 'old_string' must match exactly once unless 'occurrence' or 'replace_all' is set. ` +
+	`If any edit fails, none are applied. The user is shown the combined diff and must approve it before it is applied.`
+
+type multiEditArgs struct {
+	Path  string `json:"path"`
+	Edits []Edit `json:"edits"`
+}
+
+var multiEditTool = Func[multiEditArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: multiEditDescription,
+		Name:        "multi_edit",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"path": {
+					Type: genai.TypeString,
+				},
+				"edits": {
+					Type:        genai.TypeArray,
+					Description: "The edits to apply, in order",
+					Items: &genai.Schema{
+						Type:       genai.TypeObject,
+						Properties: editProperties(),
+						Required:   []string{"old_string", "new_string"},
+					},
+				},
+			},
+			Required: []string{"path", "edits"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args multiEditArgs) (string, error) {
+		path, err := env.Workspace.Resolve(args.Path, true)
+		if err != nil {
+			return "", err
+		}
+		if err := env.checkpoint("multi_edit", []string{path}, func() error { return EditFile(path, args.Edits...) }); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Applied %d edits to %s", len(args.Edits), args.Path) + env.editDiagnostics(ctx, []string{path}), nil
+	},
+	PreviewRun: func(ctx context.Context, env *Env, args multiEditArgs) (*ApprovalRequest, error) {
+		return previewEdits(env, args.Path, args.Edits)
+	},
+}
+
+func init() {
+	builtins = append(builtins, multiEditTool)
+}
@@ -0,0 +1,395 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// devNull is the path used by unified diffs for the missing side of a created
+// or deleted file.
+const devNull = "/dev/null"
+
+// FilePatch is the part of a unified diff that changes one file.
+type FilePatch struct {
+	// OldPath and NewPath are the paths without their "a/" and "b/" prefixes.
+	// OldPath is empty for created files and NewPath for deleted ones.
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+// Hunk is a "@@ -a,b +c,d @@" block of a file patch.
+type Hunk struct {
+	Header   string
+	OldStart int // 1-based, -1 when the header has no line numbers
+	Lines    []HunkLine
+	// NoNewline records a "\ No newline at end of file" marker after the old
+	// or new side of the hunk.
+	OldNoNewline bool
+	NewNoNewline bool
+}
+
+// HunkLine is a line of a hunk; Op is ' ', '-' or '+'.
+type HunkLine struct {
+	Op   byte
+	Text string
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// ParsePatch parses a unified diff, as produced by diff -u or git diff, that
+// may touch several files. Hunk line counts are not trusted since they are
+// often wrong in hand-written patches; a hunk ends at the next hunk or file
+// header instead.
+func ParsePatch(patch string) ([]*FilePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var files []*FilePatch
+	var file *FilePatch
+	var hunk *Hunk
+	// rename is the file of a git rename header, which is completed by the
+	// ---/+++ lines when the renamed file also changes.
+	var rename *FilePatch
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file, hunk, rename = nil, nil, nil
+			var from, to string
+			for j := i + 1; j < len(lines) && !strings.HasPrefix(lines[j], "diff --git ") && !strings.HasPrefix(lines[j], "--- "); j++ {
+				if path, ok := strings.CutPrefix(lines[j], "rename from "); ok {
+					from = path
+				}
+				if path, ok := strings.CutPrefix(lines[j], "rename to "); ok {
+					to = path
+				}
+			}
+			if from != "" && to != "" {
+				rename = &FilePatch{OldPath: from, NewPath: to}
+				files = append(files, rename)
+			}
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if rename != nil {
+				file, rename = rename, nil
+			} else {
+				file = &FilePatch{}
+				files = append(files, file)
+			}
+			file.OldPath, file.NewPath = patchPath(line[4:]), patchPath(lines[i+1][4:])
+			if file.OldPath == "" && file.NewPath == "" {
+				return nil, fmt.Errorf("line %d: both sides of the file header are %s", i+1, devNull)
+			}
+			hunk = nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk before a '--- a/path' / '+++ b/path' file header", i+1)
+			}
+			file.Hunks = append(file.Hunks, Hunk{Header: line, OldStart: -1})
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			if m := hunkHeader.FindStringSubmatch(line); m != nil {
+				hunk.OldStart, _ = strconv.Atoi(m[1])
+			}
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			if n := len(hunk.Lines); n > 0 {
+				if hunk.Lines[n-1].Op != '+' {
+					hunk.OldNoNewline = true
+				}
+				if hunk.Lines[n-1].Op != '-' {
+					hunk.NewNoNewline = true
+				}
+			}
+		case hunk != nil && (line == "" || strings.ContainsRune(" -+", rune(line[0]))):
+			// Editors and models often strip the space of empty context lines.
+			if line == "" {
+				line = " "
+			}
+			hunk.Lines = append(hunk.Lines, HunkLine{Op: line[0], Text: line[1:]})
+		default:
+			// Anything else ends the hunk: git extended headers, "index"
+			// lines or prose around the patch.
+			hunk = nil
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("patch contains no file changes; expected a unified diff with '--- a/path' and '+++ b/path' headers")
+	}
+	for _, file := range files {
+		if len(file.Hunks) == 0 && file.Kind() == "modify" {
+			return nil, fmt.Errorf("file '%s' has no hunks", file.name())
+		}
+	}
+	return files, nil
+}
+
+// patchPath strips the "a/" or "b/" prefix and any timestamp from a file
+// header path. /dev/null becomes "".
+func patchPath(path string) string {
+	if before, _, ok := strings.Cut(path, "\t"); ok {
+		path = before
+	}
+	path = strings.TrimSpace(path)
+	if path == devNull {
+		return ""
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return path
+}
+
+func (f *FilePatch) name() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Kind describes the change: "create", "delete", "rename" or "modify".
+func (f *FilePatch) Kind() string {
+	switch {
+	case f.OldPath == "":
+		return "create"
+	case f.NewPath == "":
+		return "delete"
+	case f.OldPath != f.NewPath:
+		return "rename"
+	default:
+		return "modify"
+	}
+}
+
+// HunkError reports a hunk that does not match the current file content.
+type HunkError struct {
+	Path   string
+	Index  int // 1-based
+	Header string
+	Line   int // 1-based line the hunk was expected at
+	// Expected are the context and removed lines of the hunk; Actual is the
+	// numbered file content around the place the hunk should apply.
+	Expected string
+	Actual   string
+}
+
+func (e *HunkError) Error() string {
+	return fmt.Sprintf("hunk %d of %s (%s) does not match the file.\nExpected:\n%s\nActual text around line %d:\n%s", e.Index, e.Path, e.Header, e.Expected, e.Line, e.Actual)
+}
+
+// HunkResult describes where a hunk was applied when that was not exactly
+// where its header said.
+type HunkResult struct {
+	Index  int
+	Line   int
+	Offset int
+	// Fuzz names the tolerance needed to match, empty for an exact match.
+	Fuzz string
+}
+
+// patchMatcher compares a hunk line with a file line at one fuzz level.
+type patchMatcher struct {
+	name  string
+	equal func(a, b string) bool
+	// context is how many leading and trailing context lines may be ignored.
+	context int
+}
+
+var patchMatchers = []patchMatcher{
+	{name: "", equal: func(a, b string) bool { return a == b }},
+	{name: "trailing whitespace ignored", equal: func(a, b string) bool {
+		return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t")
+	}},
+	{name: "indentation ignored", equal: func(a, b string) bool {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}},
+	{name: "indentation and 1 context line ignored", context: 1, equal: func(a, b string) bool {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}},
+	{name: "indentation and 2 context lines ignored", context: 2, equal: func(a, b string) bool {
+		return strings.TrimSpace(a) == strings.TrimSpace(b)
+	}},
+}
+
+// ApplyHunks applies the hunks to content in order. Each hunk is matched
+// exactly first, closest to the line its header gives, and then with
+// increasing fuzz. On failure it returns a *HunkError.
+func ApplyHunks(path, content string, hunks []Hunk) (string, []HunkResult, error) {
+	lines := strings.Split(content, "\n")
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	if trailingNewline {
+		lines = lines[:len(lines)-1]
+	}
+
+	// ParsePatch drops carriage returns, so lines of a CRLF file are matched
+	// without theirs and added lines get one.
+	crlf := len(lines) > 0 && strings.HasSuffix(lines[0], "\r")
+
+	var results []HunkResult
+	var out []string
+	pos := 0    // next unconsumed line of lines
+	offset := 0 // distance between the previous hunk's header and match
+	for i, hunk := range hunks {
+		start, lo, hi, matcher, ok := matchHunk(lines, pos, hunk, offset)
+		if !ok {
+			return "", nil, hunkError(path, i+1, hunk, lines, pos, offset)
+		}
+		out = append(out, lines[pos:start]...)
+		cursor := start
+		for _, line := range hunk.Lines[lo:hi] {
+			switch line.Op {
+			case ' ':
+				out = append(out, lines[cursor])
+				cursor++
+			case '-':
+				cursor++
+			case '+':
+				if crlf {
+					line.Text += "\r"
+				}
+				out = append(out, line.Text)
+			}
+		}
+
+		if hunk.OldStart >= 0 {
+			nominal := hunkLine(hunk, lo)
+			if start != nominal || matcher.name != "" {
+				results = append(results, HunkResult{Index: i + 1, Line: start + 1, Offset: start - nominal, Fuzz: matcher.name})
+			}
+			// Later hunks are likely shifted by the same amount.
+			offset = start - nominal
+		}
+		pos = cursor
+		if hunk.NewNoNewline && pos == len(lines) {
+			trailingNewline = false
+		} else if hunk.OldNoNewline && pos == len(lines) {
+			trailingNewline = true
+		}
+	}
+	out = append(out, lines[pos:]...)
+
+	result := strings.Join(out, "\n")
+	if trailingNewline && len(out) > 0 {
+		result += "\n"
+	}
+	return result, results, nil
+}
+
+// matchHunk finds where the old side of the hunk occurs in lines at or after
+// pos. It returns the first file line of the match and the range of hunk
+// lines that take part in it.
+func matchHunk(lines []string, pos int, hunk Hunk, offset int) (start, lo, hi int, matcher patchMatcher, ok bool) {
+	for _, matcher := range patchMatchers {
+		lo, hi := trimContext(hunk.Lines, matcher.context)
+		if matcher.context > 0 && lo == 0 && hi == len(hunk.Lines) {
+			continue
+		}
+		old := oldSide(hunk.Lines[lo:hi])
+		if len(old) == 0 && matcher.context > 0 {
+			// Fuzz trimmed every context line of an insertion, leaving
+			// nothing to check its place against.
+			continue
+		}
+		if len(old) == 0 {
+			// A pure insertion can only be placed by its header, or at the
+			// start of an empty file.
+			if hunk.OldStart < 0 {
+				return pos, lo, hi, matcher, len(lines) == pos
+			}
+			return min(max(hunkLine(hunk, lo)+offset, pos), len(lines)), lo, hi, matcher, true
+		}
+
+		expected := pos
+		if hunk.OldStart >= 0 {
+			expected = max(hunkLine(hunk, lo)+offset, pos)
+		}
+		// Search outwards from the expected line so the closest occurrence wins.
+		for d := 0; expected-d >= pos || expected+d+len(old) <= len(lines); d++ {
+			for _, at := range []int{expected + d, expected - d} {
+				if at >= pos && at+len(old) <= len(lines) && linesEqual(lines[at:at+len(old)], old, matcher.equal) {
+					return at, lo, hi, matcher, true
+				}
+				if d == 0 {
+					break
+				}
+			}
+		}
+	}
+	return 0, 0, 0, patchMatcher{}, false
+}
+
+// hunkLine returns the 0-based file line the header places hunk line lo at.
+// A hunk without old lines, "@@ -N,0 ...", inserts after line N.
+func hunkLine(hunk Hunk, lo int) int {
+	if len(oldSide(hunk.Lines)) == 0 {
+		return hunk.OldStart
+	}
+	return hunk.OldStart - 1 + lo
+}
+
+// trimContext drops up to n context lines from both ends of a hunk, never
+// dropping removed or added lines.
+func trimContext(lines []HunkLine, n int) (lo, hi int) {
+	lo, hi = 0, len(lines)
+	for lo < hi && lo < n && lines[lo].Op == ' ' {
+		lo++
+	}
+	for hi > lo && len(lines)-hi < n && lines[hi-1].Op == ' ' {
+		hi--
+	}
+	return lo, hi
+}
+
+func oldSide(lines []HunkLine) []string {
+	var old []string
+	for _, line := range lines {
+		if line.Op != '+' {
+			old = append(old, line.Text)
+		}
+	}
+	return old
+}
+
+// linesEqual compares file lines a with hunk lines b, ignoring the carriage
+// returns of a.
+func linesEqual(a, b []string, equal func(a, b string) bool) bool {
+	for i := range a {
+		if !equal(strings.TrimSuffix(a[i], "\r"), b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkError builds the error for a hunk that matched nowhere, quoting the
+// file around the line the hunk was expected at.
+func hunkError(path string, index int, hunk Hunk, lines []string, pos, offset int) *HunkError {
+	old := oldSide(hunk.Lines)
+	expected := pos
+	if hunk.OldStart >= 0 {
+		expected = max(hunk.OldStart-1+offset, 0)
+	}
+	from := max(min(expected, len(lines))-3, 0)
+	to := min(expected+len(old)+3, len(lines))
+
+	var actual strings.Builder
+	for n := from; n < to; n++ {
+		fmt.Fprintf(&actual, "%6d\t%s\n", n+1, lines[n])
+	}
+	if actual.Len() == 0 {
+		actual.WriteString("(end of file)\n")
+	}
+	return &HunkError{
+		Path:     path,
+		Index:    index,
+		Header:   hunk.Header,
+		Line:     expected + 1,
+		Expected: strings.Join(old, "\n"),
+		Actual:   strings.TrimSuffix(actual.String(), "\n"),
+	}
+}
@@ -0,0 +1,331 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    []FilePatch
+		kinds   []string
+		wantErr string
+	}{
+		{
+			name:  "modify",
+			patch: "--- a/main.go\t2024-01-01 00:00:00\n+++ b/main.go\n@@ -1,3 +1,3 @@\n a\n-b\n+c\n\n",
+			want: []FilePatch{{OldPath: "main.go", NewPath: "main.go", Hunks: []Hunk{{
+				Header: "@@ -1,3 +1,3 @@", OldStart: 1,
+				Lines: []HunkLine{{' ', "a"}, {'-', "b"}, {'+', "c"}, {' ', ""}},
+			}}}},
+			kinds: []string{"modify"},
+		},
+		{
+			name:  "create",
+			patch: "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+x\n\\ No newline at end of file\n",
+			want: []FilePatch{{NewPath: "new.txt", Hunks: []Hunk{{
+				Header: "@@ -0,0 +1 @@", OldStart: 0,
+				Lines:        []HunkLine{{'+', "x"}},
+				NewNoNewline: true,
+			}}}},
+			kinds: []string{"create"},
+		},
+		{
+			name:  "delete",
+			patch: "--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-x\n",
+			want: []FilePatch{{OldPath: "old.txt", Hunks: []Hunk{{
+				Header: "@@ -1 +0,0 @@", OldStart: 1,
+				Lines: []HunkLine{{'-', "x"}},
+			}}}},
+			kinds: []string{"delete"},
+		},
+		{
+			name:  "git rename without changes",
+			patch: "diff --git a/x.go b/y.go\nsimilarity index 100%\nrename from x.go\nrename to y.go\n",
+			want:  []FilePatch{{OldPath: "x.go", NewPath: "y.go"}},
+			kinds: []string{"rename"},
+		},
+		{
+			name: "git rename with changes and a second file",
+			patch: "diff --git a/x.go b/y.go\nsimilarity index 90%\nrename from x.go\nrename to y.go\nindex 1234..5678 100644\n--- a/x.go\n+++ b/y.go\n@@ -1 +1 @@\n-a\n+b\n" +
+				"diff --git a/z.go b/z.go\n--- a/z.go\n+++ b/z.go\n@@ 2 @@\n+z\n",
+			want: []FilePatch{
+				{OldPath: "x.go", NewPath: "y.go", Hunks: []Hunk{{Header: "@@ -1 +1 @@", OldStart: 1, Lines: []HunkLine{{'-', "a"}, {'+', "b"}}}}},
+				{OldPath: "z.go", NewPath: "z.go", Hunks: []Hunk{{Header: "@@ 2 @@", OldStart: -1, Lines: []HunkLine{{'+', "z"}}}}},
+			},
+			kinds: []string{"rename", "modify"},
+		},
+		{
+			name:  "prose around the patch",
+			patch: "Here is the fix:\n--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\r\n-a\r\n+b\r\nThat's all.\n",
+			want: []FilePatch{{OldPath: "a.txt", NewPath: "a.txt", Hunks: []Hunk{{
+				Header: "@@ -1 +1 @@", OldStart: 1,
+				Lines: []HunkLine{{'-', "a"}, {'+', "b"}},
+			}}}},
+			kinds: []string{"modify"},
+		},
+		{name: "no files", patch: "just prose\n", wantErr: "patch contains no file changes"},
+		{name: "hunk before header", patch: "@@ -1 +1 @@\n-a\n+b\n", wantErr: "line 1: hunk before"},
+		{name: "modify without hunks", patch: "--- a/a.txt\n+++ b/a.txt\n", wantErr: "file 'a.txt' has no hunks"},
+		{name: "both sides dev null", patch: "--- /dev/null\n+++ /dev/null\n", wantErr: "both sides of the file header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ParsePatch(tt.patch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []FilePatch
+			var kinds []string
+			for _, file := range files {
+				got = append(got, *file)
+				kinds = append(kinds, file.Kind())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePatch() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(kinds, tt.kinds) {
+				t.Errorf("kinds = %v, want %v", kinds, tt.kinds)
+			}
+		})
+	}
+}
+
+func TestApplyHunks(t *testing.T) {
+	const file = "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	tests := []struct {
+		name        string
+		content     string
+		hunks       string
+		want        string
+		wantResults []HunkResult
+		wantErr     string
+	}{
+		{
+			name:    "exact",
+			content: file,
+			hunks:   "@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+			want:    "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\n",
+		},
+		{
+			name:    "offset carries over to the next hunk",
+			content: file,
+			hunks:   "@@ -1,2 +1,2 @@\n four\n-five\n+FIVE\n@@ -5,2 +5,2 @@\n eight\n-nine\n+NINE\n",
+			want:    "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\neight\nNINE\nten\n",
+			wantResults: []HunkResult{
+				{Index: 1, Line: 4, Offset: 3},
+				{Index: 2, Line: 8, Offset: 3},
+			},
+		},
+		{
+			name:    "closest occurrence wins",
+			content: "x\na\nb\nx\nc\nd\nx\n",
+			hunks:   "@@ -5,1 +5,1 @@\n-x\n+y\n",
+			want:    "x\na\nb\ny\nc\nd\nx\n",
+			wantResults: []HunkResult{
+				{Index: 1, Line: 4, Offset: -1},
+			},
+		},
+		{
+			name:        "trailing whitespace",
+			content:     "a  \nb\nc\n",
+			hunks:       "@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+			want:        "a  \nB\nc\n",
+			wantResults: []HunkResult{{Index: 1, Line: 1, Fuzz: "trailing whitespace ignored"}},
+		},
+		{
+			name:        "indentation",
+			content:     "func f() {\n\treturn 1\n}\n",
+			hunks:       "@@ -1,3 +1,3 @@\n func f() {\n-    return 1\n+    return 2\n }\n",
+			want:        "func f() {\n    return 2\n}\n",
+			wantResults: []HunkResult{{Index: 1, Line: 1, Fuzz: "indentation ignored"}},
+		},
+		{
+			name:        "one context line ignored",
+			content:     file,
+			hunks:       "@@ -2,3 +2,3 @@\n TWO\n-three\n+THREE\n four\n",
+			want:        "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\n",
+			wantResults: []HunkResult{{Index: 1, Line: 3, Fuzz: "indentation and 1 context line ignored"}},
+		},
+		{
+			name:        "two context lines ignored",
+			content:     file,
+			hunks:       "@@ -1,6 +1,6 @@\n ONE\n TWO\n-three\n+THREE\n four\n FIVE\n SIX\n",
+			want:        "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\neight\nnine\nten\n",
+			wantResults: []HunkResult{{Index: 1, Line: 3, Fuzz: "indentation and 2 context lines ignored"}},
+		},
+		{
+			name:    "insertion placed by its header",
+			content: file,
+			hunks:   "@@ -3,0 +4 @@\n+3.5\n",
+			want:    "one\ntwo\nthree\n3.5\nfour\nfive\nsix\nseven\neight\nnine\nten\n",
+		},
+		{
+			name:    "insertion into an empty file",
+			content: "",
+			hunks:   "@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want:    "a\nb\n",
+		},
+		{
+			// Fuzz used to trim the context of an insertion and then place it
+			// by its header alone, inserting it wherever the header said.
+			name:    "insertion with context that matches nowhere",
+			content: file,
+			hunks:   "@@ -3,2 +3,3 @@\n missing\n+3.5\n gone\n",
+			wantErr: "hunk 1 of f.txt (@@ -3,2 +3,3 @@) does not match the file",
+		},
+		{
+			name:    "remove the trailing newline",
+			content: "a\nb\n",
+			hunks:   "@@ -2 +2 @@\n-b\n+b\n\\ No newline at end of file\n",
+			want:    "a\nb",
+		},
+		{
+			name:    "add a trailing newline",
+			content: "a\nb",
+			hunks:   "@@ -2 +2 @@\n-b\n\\ No newline at end of file\n+b\n",
+			want:    "a\nb\n",
+		},
+		{
+			name:    "CRLF file",
+			content: "a\r\nb\r\nc\r\n",
+			hunks:   "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "a\r\nB\r\nc\r\n",
+		},
+		{
+			name:    "second hunk overlapping the first",
+			content: file,
+			hunks:   "@@ -2,2 +2,2 @@\n two\n-three\n+THREE\n@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n",
+			wantErr: "hunk 2 of f.txt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ParsePatch("--- a/f.txt\n+++ b/f.txt\n" + tt.hunks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, results, err := ApplyHunks("f.txt", tt.content, files[0].Hunks)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ApplyHunks() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(results, tt.wantResults) {
+				t.Errorf("results = %+v, want %+v", results, tt.wantResults)
+			}
+		})
+	}
+}
+
+func TestHunkError(t *testing.T) {
+	const file = "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	files, err := ParsePatch("--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-one\n+ONE\n@@ -6,2 +6,2 @@\n six\n-SEVEN\n+7\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = ApplyHunks("f.txt", file, files[0].Hunks)
+	var hunkErr *HunkError
+	if !errors.As(err, &hunkErr) {
+		t.Fatalf("error = %v, want a *HunkError", err)
+	}
+	want := &HunkError{
+		Path:     "f.txt",
+		Index:    2,
+		Header:   "@@ -6,2 +6,2 @@",
+		Line:     6,
+		Expected: "six\nSEVEN",
+		Actual:   "     3\tthree\n     4\tfour\n     5\tfive\n     6\tsix\n     7\tseven\n     8\teight\n     9\tnine\n    10\tten",
+	}
+	if !reflect.DeepEqual(hunkErr, want) {
+		t.Errorf("HunkError =\n%+v\nwant\n%+v", hunkErr, want)
+	}
+}
+
+func TestApplyPatchTool(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	write("a.txt", "a1\na2\na3\n")
+	write("b.txt", "b1\nb2\nb3\n")
+	write("old.txt", "x\n")
+	write("gone.txt", "bye\n")
+	ws, err := NewWorkspace(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &Env{Workspace: ws}
+
+	t.Run("second file fails", func(t *testing.T) {
+		patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n a1\n-a2\n+A2\n a3\n" +
+			"--- a/b.txt\n+++ b/b.txt\n@@ -1,3 +1,3 @@\n b1\n-nope\n+B2\n b3\n"
+		_, err := applyPatchTool.Run(context.Background(), env, applyPatchArgs{Patch: patch})
+		if err == nil || !strings.Contains(err.Error(), "no files were changed") || !strings.Contains(err.Error(), "hunk 1 of b.txt") {
+			t.Fatalf("error = %v, want the b.txt hunk to be reported", err)
+		}
+		if got := read("a.txt"); got != "a1\na2\na3\n" {
+			t.Errorf("a.txt was changed to %q", got)
+		}
+	})
+
+	t.Run("every kind of change", func(t *testing.T) {
+		patch := "--- a/a.txt\n+++ b/a.txt\n@@ -2 +2 @@\n-a2\n+A2\n" +
+			"--- /dev/null\n+++ b/dir/new.txt\n@@ -0,0 +1 @@\n+new\n" +
+			"--- a/gone.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-bye\n" +
+			"diff --git a/old.txt b/moved.txt\nrename from old.txt\nrename to moved.txt\n"
+		out, err := applyPatchTool.Run(context.Background(), env, applyPatchArgs{Patch: patch})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "Patch applied:\nM a.txt\nA dir/new.txt\nD gone.txt\nR old.txt -> moved.txt"
+		if out != want {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+		if got := read("a.txt"); got != "a1\nA2\na3\n" {
+			t.Errorf("a.txt = %q", got)
+		}
+		if got := read("dir/new.txt"); got != "new\n" {
+			t.Errorf("dir/new.txt = %q", got)
+		}
+		if got := read("moved.txt"); got != "x\n" {
+			t.Errorf("moved.txt = %q", got)
+		}
+		for _, name := range []string{"gone.txt", "old.txt"} {
+			if _, err := os.Stat(filepath.Join(root, name)); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s still exists: %v", name, err)
+			}
+		}
+	})
+}
@@ -1,30 +1,69 @@
 package tools
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"google.golang.org/genai"
 )
 
-const readFileDescription = "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names."
-
-var readFileTool = &genai.Tool{
-	FunctionDeclarations: []*genai.FunctionDeclaration{
-		{
-			Description: readFileDescription,
-			Name:        "read_file",
-			Parameters: &genai.Schema{
-				Type: genai.TypeObject,
-				Properties: map[string]*genai.Schema{
-					"path": {
-						Type: genai.TypeString,
-					}},
+const readFileDescription = "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names. " +
+	"Every line is prefixed with its line number and a tab, which are not part of the file. " +
+	"Large files are truncated; use start_line and end_line to read them in parts. Binary files are summarised instead of returned."
+
+// defaultReadBytes caps the output of read_file unless Env.MaxReadBytes is set.
+const defaultReadBytes = 100 * 1024
+
+type readFileArgs struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+var readFileTool = Func[readFileArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: readFileDescription,
+		Name:        "read_file",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"path": {
+					Type: genai.TypeString,
+				},
+				"start_line": {
+					Type:        genai.TypeInteger,
+					Description: "First line to read, starting at 1 (default: 1)",
+					Minimum:     genai.Ptr(1.0),
+				},
+				"end_line": {
+					Type:        genai.TypeInteger,
+					Description: "Last line to read, inclusive (default: the end of the file)",
+					Minimum:     genai.Ptr(1.0),
+				},
 			},
+			Required: []string{"path"},
 		},
 	},
+	Run: func(ctx context.Context, env *Env, args readFileArgs) (string, error) {
+		path, err := env.Workspace.Resolve(args.Path, false)
+		if err != nil {
+			return "", err
+		}
+		return ReadFileLines(path, args.StartLine, args.EndLine, env.MaxReadBytes)
+	},
+}
+
+func init() {
+	builtins = append(builtins, readFileTool)
 }
 
+// ReadFile returns the raw contents of a file.
 func ReadFile(filePath string) (string, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -32,3 +71,88 @@ func ReadFile(filePath string) (string, error) {
 	}
 	return string(data), nil
 }
+
+// ReadFileLines returns lines start to end of a text file, numbered and
+// capped at maxBytes of output. Zero values select the whole file and the
+// default cap. Binary and non UTF-8 files return a one line summary.
+func ReadFileLines(filePath string, start, end, maxBytes int) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file '%s': %w", filePath, err)
+	}
+	text, ok := decodeText(data)
+	if !ok {
+		return fmt.Sprintf("[binary or non-UTF-8 file: %d bytes, detected type %s]", len(data), http.DetectContentType(data)), nil
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultReadBytes
+	}
+
+	lines := strings.Split(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	total := len(lines)
+	if start <= 0 {
+		start = 1
+	}
+	if end <= 0 || end > total {
+		end = total
+	}
+	if total == 0 {
+		return "[empty file]", nil
+	}
+	if start > total {
+		return "", fmt.Errorf("start_line %d is past the end of the file (%d lines)", start, total)
+	}
+	if start > end {
+		return "", fmt.Errorf("start_line %d is after end_line %d", start, end)
+	}
+
+	var out strings.Builder
+	for n := start; n <= end; n++ {
+		line := fmt.Sprintf("%6d\t%s\n", n, lines[n-1])
+		if out.Len()+len(line) > maxBytes {
+			if n == start {
+				// Show at least part of a single huge line.
+				cut := maxBytes
+				for cut > 0 && !utf8.RuneStart(line[cut]) {
+					cut--
+				}
+				out.WriteString(line[:cut] + "\n")
+			}
+			fmt.Fprintf(&out, "[truncated at %d bytes: showing lines %d-%d of %d; continue with start_line=%d]\n", maxBytes, start, max(start, n-1), total, max(start+1, n))
+			return out.String(), nil
+		}
+		out.WriteString(line)
+	}
+	if start > 1 || end < total {
+		fmt.Fprintf(&out, "[showing lines %d-%d of %d]\n", start, end, total)
+	}
+	return out.String(), nil
+}
+
+// decodeText returns data as UTF-8 text. Byte order marks are honoured, so
+// UTF-16 files are converted; other encodings and binary data are rejected.
+func decodeText(data []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 }), true
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], func(b []byte) uint16 { return uint16(b[1]) | uint16(b[0])<<8 }), true
+	}
+	if isBinary(data) || !utf8.Valid(data) {
+		return "", false
+	}
+	return string(data), true
+}
+
+func decodeUTF16(data []byte, unit func([]byte) uint16) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, unit(data[i:i+2]))
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+const readSymbolDescription = `Return the source of a named top-level declaration in a Go file or in the Go package in a directory, with its doc comment and line numbers. ` +
+	`Name functions, types, constants and variables by their name and methods as 'Type.Method'. ` +
+	`Use code_outline to find the names. Only Go files are supported.`
+
+type readSymbolArgs struct {
+	Path   string `json:"path"`
+	Symbol string `json:"symbol"`
+}
+
+var readSymbolTool = Func[readSymbolArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: readSymbolDescription,
+		Name:        "read_symbol",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"path": {
+					Type:        genai.TypeString,
+					Description: "A .go file, or a directory to search the package in it",
+				},
+				"symbol": {
+					Type:        genai.TypeString,
+					Description: "The declaration to read, e.g. 'New', 'Tools' or 'Tools.Register'",
+				},
+			},
+			Required: []string{"path", "symbol"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args readSymbolArgs) (string, error) {
+		path, err := env.Workspace.Resolve(args.Path, false)
+		if err != nil {
+			return "", err
+		}
+		return ReadSymbol(path, args.Path, args.Symbol)
+	},
+}
+
+func init() {
+	builtins = append(builtins, readSymbolTool)
+}
+
+// ReadSymbol returns the numbered source of the declaration named symbol in
+// the Go file or package at path, preceded by its location. name is how path
+// is shown to the model.
+func ReadSymbol(path, name, symbol string) (string, error) {
+	files, err := parseGoFiles(path, name, true)
+	if err != nil {
+		return "", err
+	}
+	symbol = strings.TrimPrefix(strings.TrimSpace(symbol), "*")
+
+	var b strings.Builder
+	var names []string
+	for _, f := range files {
+		for _, sym := range f.symbols() {
+			names = append(names, sym.QualifiedName())
+			if sym.QualifiedName() != symbol {
+				continue
+			}
+			// Several files may declare the symbol under different build
+			// constraints; return them all.
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s:%d-%d (%s)\n", sym.File, f.fset.Position(sym.start).Line, sym.EndLine, sym.Kind)
+			b.WriteString(f.source(sym.start, sym.end))
+		}
+	}
+	if b.Len() == 0 {
+		names = sortedUnique(names)
+		if len(names) > 100 {
+			names = append(names[:100], "...")
+		}
+		return "", fmt.Errorf("symbol '%s' is not declared in '%s'; declared symbols: %s", symbol, name, strings.Join(names, ", "))
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,347 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"google.golang.org/genai"
+)
+
+const renameSymbolDescription = `Rename a Go identifier and every reference to it across the packages of the workspace, including tests, using the type checker. ` +
+	`Name the identifier as for find_references. The renamed code is type-checked before anything is written: ` +
+	`the rename is refused if it would break the build or make a reference resolve to a different declaration, e.g. through shadowing, ` +
+	`and methods are not renamed while their type satisfies an interface declaring them. ` +
+	`The user is shown the diff of every file and must approve it; all files are then changed at once.`
+
+type renameSymbolArgs struct {
+	findReferencesArgs
+	NewName string `json:"new_name"`
+}
+
+var renameSymbolTool = Func[renameSymbolArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: renameSymbolDescription,
+		Name:        "rename_symbol",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: func() map[string]*genai.Schema {
+				props := goSymbolProperties()
+				props["new_name"] = &genai.Schema{
+					Type:        genai.TypeString,
+					Description: "The new name of the identifier",
+				}
+				return props
+			}(),
+			Required: []string{"path", "symbol", "new_name"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args renameSymbolArgs) (string, error) {
+		plan, err := planRename(ctx, env, args)
+		if err != nil {
+			return "", err
+		}
+		paths := make([]string, len(plan.changes))
+		for i, change := range plan.changes {
+			paths[i] = change.Path
+		}
+		if err := env.checkpoint("rename_symbol", paths, func() error { return ApplyChanges(plan.changes) }); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Renamed %s to %s: %d reference(s) in %d file(s):\n%s",
+			args.Symbol, args.NewName, plan.references, len(plan.changes), strings.Join(plan.files, "\n")) + env.editDiagnostics(ctx, paths), nil
+	},
+	PreviewRun: func(ctx context.Context, env *Env, args renameSymbolArgs) (*ApprovalRequest, error) {
+		plan, err := planRename(ctx, env, args)
+		if err != nil {
+			return nil, err
+		}
+		return &ApprovalRequest{
+			Summary: fmt.Sprintf("Rename %s to %s in %d file(s)", args.Symbol, args.NewName, len(plan.changes)),
+			Preview: strings.Join(plan.diffs, ""),
+		}, nil
+	},
+}
+
+func init() {
+	builtins = append(builtins, renameSymbolTool)
+}
+
+// renamePlan holds the file changes a rename makes, computed and checked
+// before anything is written.
+type renamePlan struct {
+	changes    []FileChange
+	files      []string
+	diffs      []string
+	references int
+}
+
+// planRename renames the identifier in memory and type-checks the result.
+func planRename(ctx context.Context, env *Env, args renameSymbolArgs) (*renamePlan, error) {
+	newName := args.NewName
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return nil, fmt.Errorf("'%s' is not a valid Go identifier", newName)
+	}
+	path, err := env.Workspace.Resolve(args.Path, false)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := loadGoProgram(ctx, env.Workspace.Dir(), nil)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := prog.objectAt(path, args.Symbol, args.Line)
+	if err != nil {
+		return nil, err
+	}
+	oldName := obj.Name()
+	switch {
+	case oldName == newName:
+		return nil, fmt.Errorf("'%s' is already named %s", args.Symbol, newName)
+	case obj.Pkg() == nil:
+		return nil, fmt.Errorf("cannot rename the predeclared identifier '%s'", oldName)
+	case isPkgName(obj):
+		return nil, errors.New("cannot rename imports; edit the import declaration instead")
+	}
+	// Renaming a method can silently stop its type from satisfying an
+	// interface that is only asserted at run time, which type-checking the
+	// result cannot notice.
+	if method, ok := obj.(*types.Func); ok {
+		if satisfied := prog.interfacesSatisfiedBy(method); len(satisfied) > 0 {
+			return nil, fmt.Errorf("cannot rename '%s': types with the method satisfy interfaces declaring it, and code converting them at run time, e.g. with a type assertion, would stop matching without a compile error:\n- %s",
+				oldName, strings.Join(satisfied, "\n- "))
+		}
+	}
+
+	// Replace every reference, file by file, remembering where the
+	// references will be once renamed.
+	refs := prog.references(obj)
+	if errs := prog.errorsAffecting(obj, refs); len(errs) > 0 {
+		return nil, fmt.Errorf("cannot rename '%s': packages using it do not type-check, so references may be missed; fix them first:\n- %s",
+			oldName, strings.Join(errs, "\n- "))
+	}
+	byFile := make(map[string][]*GoReference)
+	var filenames []string
+	for _, ref := range refs {
+		if byFile[ref.filename] == nil {
+			filenames = append(filenames, ref.filename)
+		}
+		byFile[ref.filename] = append(byFile[ref.filename], ref)
+	}
+	slices.Sort(filenames)
+
+	plan := &renamePlan{references: len(refs)}
+	overlay := make(map[string][]byte)
+	expected := make(map[string]bool)
+	var declFile string
+	declOffset := -1
+	for _, filename := range filenames {
+		if _, err := env.Workspace.Resolve(filename, true); err != nil {
+			return nil, fmt.Errorf("cannot rename '%s': it is referenced in %s: %w", oldName, filename, err)
+		}
+		old, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %w", filename, err)
+		}
+		var b strings.Builder
+		last, shift := 0, 0
+		for _, ref := range byFile[filename] {
+			if string(old[ref.offset:min(ref.offset+len(oldName), len(old))]) != oldName {
+				return nil, fmt.Errorf("cannot rename '%s': %s:%d:%d does not match the source, the file may be generated", oldName, ref.File, ref.Line, ref.Column)
+			}
+			b.Write(old[last:ref.offset])
+			b.WriteString(newName)
+			last = ref.offset + len(oldName)
+
+			newOffset := ref.offset + shift
+			expected[fmt.Sprintf("%s:%d", filename, newOffset)] = true
+			if ref.Declaration {
+				declFile, declOffset = filename, newOffset
+			}
+			shift += len(newName) - len(oldName)
+		}
+		b.Write(old[last:])
+		content := b.String()
+
+		rel := prog.relative(filename)
+		overlay[filename] = []byte(content)
+		plan.changes = append(plan.changes, FileChange{Path: filename, Content: []byte(content)})
+		plan.files = append(plan.files, fmt.Sprintf("M %s (%d)", rel, len(byFile[filename])))
+		plan.diffs = append(plan.diffs, FileDiff(rel, string(old), content, false))
+	}
+	if declOffset < 0 {
+		return nil, fmt.Errorf("cannot rename '%s': it is not declared in the workspace", oldName)
+	}
+
+	if err := verifyRename(ctx, prog, overlay, declFile, declOffset, expected, newName); err != nil {
+		return nil, fmt.Errorf("renaming %s to %s was refused, no files were changed: %w", oldName, newName, err)
+	}
+	return plan, nil
+}
+
+// verifyRename type-checks the renamed code. It fails on errors the code did
+// not have before and when the references of the renamed declaration are not
+// exactly the renamed identifiers, which happens when the new name shadows or
+// is shadowed by another declaration.
+func verifyRename(ctx context.Context, prog *goProgram, overlay map[string][]byte, declFile string, declOffset int, expected map[string]bool, newName string) error {
+	renamed, err := loadGoProgram(ctx, prog.dir, overlay)
+	if err != nil {
+		return err
+	}
+	// Positions move with the renamed identifiers, so errors are told apart
+	// by file and message, counting repeats.
+	before := make(map[string]int)
+	for _, err := range prog.errors() {
+		before[errorKey(err)]++
+	}
+	var broken []string
+	for _, err := range renamed.errors() {
+		if key := errorKey(err); before[key] > 0 {
+			before[key]--
+		} else {
+			broken = append(broken, err.Error())
+		}
+	}
+	if len(broken) > 0 {
+		if len(broken) > 10 {
+			broken = append(broken[:10], "...")
+		}
+		return fmt.Errorf("the renamed code does not compile:\n- %s", strings.Join(broken, "\n- "))
+	}
+
+	obj := renamed.objectDefinedAt(declFile, declOffset)
+	if obj == nil {
+		return errors.New("the renamed declaration could not be found")
+	}
+	var changed []string
+	got := make(map[string]bool)
+	for _, ref := range renamed.references(obj) {
+		key := fmt.Sprintf("%s:%d", ref.filename, ref.offset)
+		got[key] = true
+		if !expected[key] {
+			changed = append(changed, fmt.Sprintf("%s:%d:%d now refers to it", ref.File, ref.Line, ref.Column))
+		}
+	}
+	for key := range expected {
+		if !got[key] {
+			changed = append(changed, fmt.Sprintf("%s no longer refers to it", renamed.relative(key)))
+		}
+	}
+	if len(changed) > 0 {
+		slices.Sort(changed)
+		return fmt.Errorf("'%s' would change what other code refers to:\n- %s", newName, strings.Join(changed, "\n- "))
+	}
+	return nil
+}
+
+// interfacesSatisfiedBy lists the interfaces that declare a method named like
+// method and that a workspace type with method in its method set satisfies,
+// as "Interface (Type)". Interfaces of dependencies, such as fmt.Stringer,
+// are included. Methods of interfaces themselves are not checked.
+func (prog *goProgram) interfacesSatisfiedBy(method *types.Func) []string {
+	sig, ok := method.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil || types.IsInterface(sig.Recv().Type()) {
+		return nil
+	}
+	name := method.Name()
+	target := prog.fset.Position(method.Pos())
+	workspace := make(map[string]bool)
+	for _, pkg := range prog.pkgs {
+		workspace[pkg.PkgPath] = true
+	}
+
+	var satisfied []string
+	seen := make(map[string]bool)
+	for _, pkg := range prog.pkgs {
+		if pkg.Types == nil {
+			continue
+		}
+		// Types are compared within the import graph of one package
+		// variant, where every type exists once.
+		var concrete []types.Type
+		var ifaces []*types.Named
+		packages.Visit([]*packages.Package{pkg}, nil, func(p *packages.Package) {
+			if p.Types == nil {
+				return
+			}
+			scope := p.Types.Scope()
+			for _, n := range scope.Names() {
+				tn, ok := scope.Lookup(n).(*types.TypeName)
+				if !ok || tn.IsAlias() {
+					continue
+				}
+				named, ok := tn.Type().(*types.Named)
+				if !ok || named.TypeParams().Len() > 0 {
+					continue
+				}
+				if iface, ok := named.Underlying().(*types.Interface); ok {
+					for m := range iface.Methods() {
+						if m.Name() == name {
+							ifaces = append(ifaces, named)
+							break
+						}
+					}
+					continue
+				}
+				if !workspace[p.PkgPath] {
+					continue
+				}
+				for _, t := range []types.Type{named, types.NewPointer(named)} {
+					obj, _, _ := types.LookupFieldOrMethod(t, true, tn.Pkg(), name)
+					if obj == nil {
+						continue
+					}
+					if pos := prog.fset.Position(obj.Pos()); pos.Filename == target.Filename && pos.Offset == target.Offset {
+						concrete = append(concrete, t)
+					}
+				}
+			}
+		})
+		for _, iface := range ifaces {
+			for _, t := range concrete {
+				if !types.Implements(t, iface.Underlying().(*types.Interface)) {
+					continue
+				}
+				entry := fmt.Sprintf("%s (%s)", types.TypeString(iface, packageName), types.TypeString(t, packageName))
+				if !seen[entry] {
+					seen[entry] = true
+					satisfied = append(satisfied, entry)
+				}
+				break
+			}
+		}
+	}
+	slices.Sort(satisfied)
+	return satisfied
+}
+
+// packageName qualifies type names by package name, like Go source does.
+func packageName(pkg *types.Package) string {
+	return pkg.Name()
+}
+
+func isPkgName(obj types.Object) bool {
+	_, ok := obj.(*types.PkgName)
+	return ok
+}
+
+// errorKey identifies a load error by its file and message.
+func errorKey(err packages.Error) string {
+	file := err.Pos
+	// Pos is "file:line:col", "file:line" or empty.
+	for range 2 {
+		if i := strings.LastIndex(file, ":"); i >= 0 {
+			if _, convErr := strconv.Atoi(file[i+1:]); convErr == nil {
+				file = file[:i]
+			}
+		}
+	}
+	return file + "\x00" + err.Msg
+}
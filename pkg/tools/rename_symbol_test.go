@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenameSymbol(t *testing.T) {
+	tests := []struct {
+		name string
+		args renameSymbolArgs
+		// files are added to the fixture before renaming.
+		files map[string]string
+		// want maps files to text they must contain after the rename.
+		want    map[string][]string
+		wantErr string
+	}{
+		{
+			name: "function across packages",
+			args: renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Scale"}, "Resize"},
+			want: map[string][]string{
+				"shapes/shapes.go":      {"func Resize(s Square, factor int) int {"},
+				"shapes/label_test.go":  {"shapes.Resize(sq, 2)"},
+				"main.go":               {"shapes.Resize(sq, 2)"},
+				"shapes/shapes_test.go": {"(Square{Side: 3}).Area()"},
+			},
+		},
+		{
+			name: "local variable by line",
+			args: renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "total", Line: 23}, "sum"},
+			want: map[string][]string{
+				"shapes/shapes.go": {"sum := s.Area()", "scaled := sum * factor"},
+			},
+		},
+		{
+			name: "type used as an embedded field",
+			args: renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Label"}, "Tag"},
+			want: map[string][]string{
+				"shapes/shapes.go":     {"type Tag struct {", "\tTag\n"},
+				"shapes/label_test.go": {"shapes.Square{Tag: shapes.Tag{Text: \"a\"}", "sq.Tag.Text"},
+				"main.go":              {"sq.Tag.Text = \"four\""},
+			},
+		},
+		{
+			name: "method referenced from a test",
+			args: renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Square.Area"}, "Surface"},
+			want: map[string][]string{
+				"shapes/shapes.go":      {"func (s Square) Surface() int {", "total := s.Surface()"},
+				"shapes/shapes_test.go": {"(Square{Side: 3}).Surface()"},
+				"main.go":               {"sq.Surface()"},
+			},
+		},
+		{
+			name: "method of an interface only asserted at run time",
+			args: renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Square.Area"}, "Surface"},
+			files: map[string]string{"shapes/sizer.go": "package shapes\n\ntype Sizer interface {\n\tArea() int\n}\n\n" +
+				"func SizeOf(v any) int {\n\tif s, ok := v.(Sizer); ok {\n\t\treturn s.Area()\n\t}\n\treturn 0\n}\n"},
+			wantErr: "- shapes.Sizer (shapes.Square)",
+		},
+		{
+			name:    "method of an interface declared by an importer",
+			args:    renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Square.Area"}, "Surface"},
+			files:   map[string]string{"sizer.go": "package main\n\ntype sizer interface{ Area() int }\n\nvar _, _ = any(nil).(sizer)\n"},
+			wantErr: "- main.sizer (shapes.Square)",
+		},
+		{
+			name:    "new name shadows a package variable",
+			args:    renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "size", Line: 32}, "limit"},
+			wantErr: "would change what other code refers to",
+		},
+		{
+			name:    "renamed variable is shadowed by a local",
+			args:    renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "limit"}, "size"},
+			wantErr: "would change what other code refers to",
+		},
+		{
+			name:    "new name is already declared in the scope",
+			args:    renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "scaled", Line: 24}, "factor"},
+			wantErr: "the renamed code does not compile",
+		},
+		{
+			name:    "package using the symbol does not type-check",
+			args:    renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Scale"}, "Resize"},
+			files:   map[string]string{"shapes/broken.go": "package shapes\n\nvar _ = Scale(Square{}, undefinedFactor)\n"},
+			wantErr: "do not type-check",
+		},
+		{
+			name:    "invalid name",
+			args:    renameSymbolArgs{findReferencesArgs{Path: "shapes/shapes.go", Symbol: "Scale"}, "re-size"},
+			wantErr: "is not a valid Go identifier",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := filepath.Join(t.TempDir(), "gorefs")
+			if err := os.CopyFS(dir, os.DirFS("testdata/gorefs")); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ws, err := NewWorkspace(dir, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := &Env{Workspace: ws}
+
+			_, err = renameSymbolTool.Run(context.Background(), env, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				assertUnchanged(t, dir)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, wants := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, want := range wants {
+					if !strings.Contains(string(data), want) {
+						t.Errorf("%s does not contain %q:\n%s", name, want, data)
+					}
+				}
+			}
+		})
+	}
+}
+
+// assertUnchanged fails unless the fixture files in dir still match
+// testdata/gorefs.
+func assertUnchanged(t *testing.T, dir string) {
+	t.Helper()
+	err := filepath.WalkDir("testdata/gorefs", func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel("testdata/gorefs", path)
+		want, _ := os.ReadFile(path)
+		got, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			return err
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s was changed by a refused rename", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,381 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const runCommandDescription = `Run a command in the workspace, e.g. 'go build ./...', 'go test ./pkg/tools' or 'golangci-lint run'. ` +
+	`The command is executed directly, not through a shell, so pipes, redirections and '&&' are not supported; run one command per call. ` +
+	`Returns the exit code, stdout and stderr. Commands not pre-approved by the user's configuration need the user's approval, and some commands may be refused.`
+
+const (
+	defaultCommandTimeout     = 2 * time.Minute
+	defaultCommandOutputBytes = 64 * 1024
+)
+
+// defaultCommandEnv are the environment variables passed to commands when the
+// policy does not list its own.
+var defaultCommandEnv = []string{
+	"PATH", "HOME", "USER", "LANG", "TERM", "TMPDIR",
+	"GOPATH", "GOROOT", "GOCACHE", "GOMODCACHE", "GOFLAGS", "GOPROXY", "GOPRIVATE",
+}
+
+// CommandPolicy controls what run_command may execute and how.
+type CommandPolicy struct {
+	// Allow lists command prefixes that run without approval, e.g. "go test".
+	Allow []string
+	// Deny lists command prefixes that are always refused, e.g. "rm" or "git push".
+	Deny []string
+	// Timeout bounds the run time of a command. Defaults to 2 minutes.
+	Timeout time.Duration
+	// MaxOutputBytes caps stdout and stderr separately. Defaults to 64KiB.
+	MaxOutputBytes int
+	// Env lists the environment variables passed through to commands.
+	// Defaults to PATH, HOME and the Go toolchain variables.
+	Env []string
+	// NoNetwork runs commands without network access (Linux only).
+	NoNetwork bool
+}
+
+type runCommandArgs struct {
+	Command        string `json:"command"`
+	Dir            string `json:"dir"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// CommandResult is the output of run_command.
+type CommandResult struct {
+	ExitCode        int    `json:"exit_code"`
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	StdoutTruncated bool   `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool   `json:"stderr_truncated,omitempty"`
+	TimedOut        bool   `json:"timed_out,omitempty"`
+}
+
+var runCommandTool = Func[runCommandArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: runCommandDescription,
+		Name:        "run_command",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"command": {
+					Type:        genai.TypeString,
+					Description: "The command line to run, e.g. 'go test ./...'. Arguments may be quoted.",
+				},
+				"dir": {
+					Type:        genai.TypeString,
+					Description: "Directory to run the command in, relative to the workspace root (default: the workspace root)",
+				},
+				"timeout_seconds": {
+					Type:        genai.TypeInteger,
+					Description: "Timeout in seconds, capped by the configured limit",
+					Minimum:     genai.Ptr(1.0),
+				},
+			},
+			Required: []string{"command"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args runCommandArgs) (string, error) {
+		argv, dir, err := env.prepareCommand(args)
+		if err != nil {
+			return "", err
+		}
+		result, err := RunCommand(ctx, env.Commands, dir, argv, time.Duration(args.TimeoutSeconds)*time.Second)
+		if err != nil {
+			return "", err
+		}
+		out, err := json.Marshal(result)
+		if err != nil {
+			return "", fmt.Errorf("error marshaling JSON: %w", err)
+		}
+		return string(out), nil
+	},
+	PreviewRun: func(ctx context.Context, env *Env, args runCommandArgs) (*ApprovalRequest, error) {
+		argv, dir, err := env.prepareCommand(args)
+		if err != nil {
+			return nil, err
+		}
+		writing := env.writingArgs(dir, argv[1:])
+		if allowedCommand(env.Commands.Allow, argv) && !writing {
+			return nil, nil
+		}
+		network := "on"
+		if env.Commands.NoNetwork {
+			network = "off"
+		}
+		preview := fmt.Sprintf("$ %s\n(in %s, network %s)", args.Command, dir, network)
+		return &ApprovalRequest{Summary: "Run command", Preview: preview, Scope: commandScope(argv, writing)}, nil
+	},
+}
+
+func init() {
+	builtins = append(builtins, runCommandTool)
+}
+
+// prepareCommand splits the command line, checks it against the deny list and
+// resolves the working directory.
+func (env *Env) prepareCommand(args runCommandArgs) ([]string, string, error) {
+	argv, err := splitCommand(args.Command)
+	if err != nil {
+		return nil, "", err
+	}
+	if matchesCommand(env.Commands.Deny, argv) {
+		return nil, "", fmt.Errorf("command '%s' is denied by the configuration", args.Command)
+	}
+	dir, err := env.Workspace.Resolve(args.Dir, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return argv, dir, nil
+}
+
+// RunCommand executes argv in dir according to the policy. A non-zero exit
+// code is reported in the result, not as an error. timeout, when positive,
+// shortens the policy timeout.
+func RunCommand(ctx context.Context, policy CommandPolicy, dir string, argv []string, timeout time.Duration) (*CommandResult, error) {
+	limit := policy.Timeout
+	if limit <= 0 {
+		limit = defaultCommandTimeout
+	}
+	if timeout > 0 && timeout < limit {
+		limit = timeout
+	}
+	maxBytes := policy.MaxOutputBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultCommandOutputBytes
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = commandEnv(policy.Env)
+	cmd.WaitDelay = 2 * time.Second
+	if err := sandbox(cmd, policy.NoNetwork); err != nil {
+		return nil, err
+	}
+
+	stdout := &cappedBuffer{max: maxBytes}
+	stderr := &cappedBuffer{max: maxBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	result := &CommandResult{
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.truncated,
+		StderrTruncated: stderr.truncated,
+		TimedOut:        errors.Is(ctx.Err(), context.DeadlineExceeded),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case result.TimedOut:
+		result.ExitCode = -1
+	default:
+		return nil, fmt.Errorf("failed to run '%s': %w", strings.Join(argv, " "), err)
+	}
+	return result, nil
+}
+
+// commandEnv returns the allowlisted variables of the current environment.
+func commandEnv(allow []string) []string {
+	if len(allow) == 0 {
+		allow = defaultCommandEnv
+	}
+	var env []string
+	for _, name := range allow {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// matchesCommand reports whether argv starts with the words of any pattern.
+// The program name is compared without its directory, so "rm" also matches
+// "/bin/rm". It is meant for the deny list, where matching too much is safe.
+func matchesCommand(patterns []string, argv []string) bool {
+	for _, pattern := range patterns {
+		words := strings.Fields(pattern)
+		if len(words) == 0 || len(words) > len(argv) {
+			continue
+		}
+		if words[0] != argv[0] && words[0] != filepath.Base(argv[0]) {
+			continue
+		}
+		if slices.Equal(words[1:], argv[1:len(words)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedCommand reports whether argv starts with the words of an allow
+// pattern. Unlike matchesCommand the program must be the one the pattern
+// names: "go" matches "go" and the go binary found on PATH, but not "./go".
+func allowedCommand(patterns []string, argv []string) bool {
+	for _, pattern := range patterns {
+		words := strings.Fields(pattern)
+		if len(words) == 0 || len(words) > len(argv) || !sameProgram(words[0], argv[0]) {
+			continue
+		}
+		if slices.Equal(words[1:], argv[1:len(words)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFlags make commands that are otherwise safe to allow write files or
+// run other programs, e.g. "gofmt -w" or "git diff --output=FILE".
+var writeFlags = []string{
+	"-w", "--write", "-o", "-output", "--output", "-fix", "--fix",
+	"-exec", "--exec", "-toolexec", "-vettool", "--ext-diff",
+}
+
+// writingArgs reports whether args use a write flag or name a path outside
+// the workspace, so an allow-listed command still needs approval. Relative
+// paths are taken from dir, where the command runs.
+func (env *Env) writingArgs(dir string, args []string) bool {
+	for _, arg := range args {
+		value := arg
+		if strings.HasPrefix(arg, "-") {
+			flag, flagValue, _ := strings.Cut(arg, "=")
+			if slices.Contains(writeFlags, flag) {
+				return true
+			}
+			value = flagValue
+		}
+		if !filepath.IsAbs(value) && !slices.Contains(strings.Split(filepath.ToSlash(value), "/"), "..") {
+			continue
+		}
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(dir, value)
+		}
+		if _, err := env.Workspace.Resolve(value, true); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// commandScope is what an "always" answer to a command approves: commands
+// with the same program and subcommand, e.g. "go test", or only this exact
+// command when it writes files or reaches outside the workspace.
+func commandScope(argv []string, writing bool) string {
+	if writing {
+		return strings.Join(argv, " ")
+	}
+	if len(argv) > 1 && !strings.HasPrefix(argv[1], "-") {
+		return argv[0] + " " + argv[1]
+	}
+	return argv[0]
+}
+
+// sameProgram reports whether program, as given in a command, runs the
+// program name refers to.
+func sameProgram(name, program string) bool {
+	if program == name {
+		return true
+	}
+	if !filepath.IsAbs(program) {
+		return false
+	}
+	path, err := exec.LookPath(name)
+	return err == nil && path == program
+}
+
+// splitCommand splits a command line into arguments, honouring single and
+// double quotes and backslash escapes. Shell operators are rejected since the
+// command is not run by a shell.
+func splitCommand(line string) ([]string, error) {
+	var argv []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				argv = append(argv, current.String())
+				current.Reset()
+				inWord = false
+			}
+		case strings.ContainsRune("|&;<>", r):
+			return nil, fmt.Errorf("shell operator '%c' is not supported, run one command per call", r)
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in command")
+	}
+	if inWord {
+		argv = append(argv, current.String())
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("invalid argument: command is empty")
+	}
+	return argv, nil
+}
+
+// cappedBuffer keeps the first max bytes written to it. The buffer is not
+// embedded so io.Copy cannot bypass Write through bytes.Buffer.ReadFrom.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room < len(p) {
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
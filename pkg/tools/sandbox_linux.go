@@ -0,0 +1,26 @@
+//go:build linux
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// sandbox runs the command in its own process group, so a timeout kills its
+// children too, and optionally in new user and network namespaces that only
+// have a loopback interface.
+func sandbox(cmd *exec.Cmd, noNetwork bool) error {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if noNetwork {
+		attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	}
+	cmd.SysProcAttr = attr
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package tools
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// sandbox only supports network isolation on Linux.
+func sandbox(cmd *exec.Cmd, noNetwork bool) error {
+	if noNetwork {
+		return errors.New("running commands without network access is only supported on Linux")
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"google.golang.org/genai"
+)
+
+const searchDescription = `Search the contents of the workspace files for a regular expression (RE2 syntax) and return the matching lines as 'path:line:text'. ` +
+	`Use this to find definitions, usages or strings instead of reading every file. Files ignored by .gitignore and binary files are skipped.`
+
+const (
+	defaultSearchResults = 100
+	maxSearchResults     = 1000
+	maxSearchFileSize    = 8 << 20
+	maxSearchLineLength  = 300
+)
+
+type searchArgs struct {
+	Pattern      string `json:"pattern"`
+	Path         string `json:"path"`
+	Glob         string `json:"glob"`
+	IgnoreCase   bool   `json:"ignore_case"`
+	ContextLines int    `json:"context_lines"`
+	MaxResults   int    `json:"max_results"`
+}
+
+var searchTool = Func[searchArgs]{
+	Decl: &genai.FunctionDeclaration{
+		Description: searchDescription,
+		Name:        "search",
+		Parameters: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"pattern": {
+					Type:        genai.TypeString,
+					Description: "Regular expression to search for, e.g. 'func \\(\\*Tools\\) ExecuteTool'",
+				},
+				"path": {
+					Type:        genai.TypeString,
+					Description: "File or directory to search in (default: the workspace root)",
+				},
+				"glob": {
+					Type:        genai.TypeString,
+					Description: "Only search files matching this glob, e.g. '*.go' or 'pkg/**/*_test.go'. A glob with a slash is matched against the path relative to 'path', as in list_files",
+				},
+				"ignore_case": {
+					Type:        genai.TypeBoolean,
+					Description: "Match case-insensitively (default: false)",
+				},
+				"context_lines": {
+					Type:        genai.TypeInteger,
+					Description: "Number of lines to show before and after each match (default: 0)",
+					Minimum:     genai.Ptr(0.0),
+					Maximum:     genai.Ptr(20.0),
+				},
+				"max_results": {
+					Type:        genai.TypeInteger,
+					Description: fmt.Sprintf("Maximum number of matching lines to return (default: %d)", defaultSearchResults),
+					Minimum:     genai.Ptr(1.0),
+					Maximum:     genai.Ptr(float64(maxSearchResults)),
+				},
+			},
+			Required: []string{"pattern"},
+		},
+	},
+	Run: func(ctx context.Context, env *Env, args searchArgs) (string, error) {
+		path, err := env.Workspace.Resolve(args.Path, false)
+		if err != nil {
+			return "", err
+		}
+		return Search(ctx, env.Workspace, path, args)
+	},
+}
+
+func init() {
+	builtins = append(builtins, searchTool)
+}
+
+// searchResult holds the output lines of one file.
+type searchResult struct {
+	lines   []searchLine
+	matches int
+}
+
+// searchLine is a match, a context line or a "--" group separator.
+type searchLine struct {
+	text  string
+	match bool
+}
+
+// Search greps the files below path. Files are searched in parallel but
+// reported in walk order, and the walk stops once more than the requested
+// number of matches has been found.
+func Search(ctx context.Context, ws *Workspace, path string, args searchArgs) (string, error) {
+	expr := args.Pattern
+	if args.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	var glob *Glob
+	if args.Glob != "" {
+		if glob, err = CompileGlob(args.Glob); err != nil {
+			return "", err
+		}
+	}
+	maxResults := args.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultSearchResults
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to search '%s': %w", args.Path, err)
+	}
+	base := ws.Dir()
+	display := func(p string) string {
+		if rel, err := filepath.Rel(base, p); err == nil && within(base, p) {
+			return filepath.ToSlash(rel)
+		}
+		return p
+	}
+
+	// Every file gets a slot so results can be reported in walk order.
+	var results []*searchResult
+	var found atomic.Int64
+	type job struct {
+		path   string
+		result *searchResult
+	}
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for range runtime.GOMAXPROCS(0) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				searchFile(j.path, display(j.path), re, args.ContextLines, j.result)
+				found.Add(int64(j.result.matches))
+			}
+		}()
+	}
+
+	dispatch := func(p string) {
+		result := &searchResult{}
+		results = append(results, result)
+		jobs <- job{path: p, result: result}
+	}
+
+	var walkErr error
+	if info.IsDir() {
+		ig := newWorkspaceIgnore(ws, path)
+		walkErr = ig.Walk(ctx, path, func(p, _ string, d fs.DirEntry) error {
+			if found.Load() > int64(maxResults) {
+				return fs.SkipAll
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			// Globs are relative to the searched directory, as in ListFiles.
+			if rel, err := filepath.Rel(path, p); err != nil || glob != nil && !glob.Match(filepath.ToSlash(rel)) {
+				return nil
+			}
+			dispatch(p)
+			return nil
+		})
+	} else {
+		dispatch(path)
+	}
+	close(jobs)
+	wg.Wait()
+	if walkErr != nil {
+		return "", fmt.Errorf("failed to search '%s': %w", args.Path, walkErr)
+	}
+
+	var out strings.Builder
+	total := 0
+	truncated := false
+	for _, result := range results {
+		if result.matches == 0 {
+			continue
+		}
+		if total+result.matches > maxResults {
+			truncated = true
+			result.lines = limitMatches(result.lines, maxResults-total)
+		}
+		if args.ContextLines > 0 && out.Len() > 0 {
+			out.WriteString("--\n")
+		}
+		for _, line := range result.lines {
+			out.WriteString(line.text)
+			out.WriteByte('\n')
+		}
+		total += result.matches
+		if truncated {
+			break
+		}
+	}
+
+	if out.Len() == 0 {
+		return "No matches found.", nil
+	}
+	if truncated {
+		fmt.Fprintf(&out, "[results truncated after %d matches; narrow the pattern, path or glob, or raise max_results]\n", maxResults)
+	}
+	return out.String(), nil
+}
+
+// searchFile fills result with the matching lines of the file, formatted as
+// "path:line:text" and, for context lines, "path-line-text" with "--"
+// between non-adjacent groups. Binary and very large files are skipped.
+func searchFile(path, name string, re *regexp.Regexp, contextLines int, result *searchResult) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxSearchFileSize {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || isBinary(data) || !re.Match(data) {
+		return
+	}
+
+	lines := strings.Split(string(data), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	last := -1
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if !re.MatchString(line) {
+			continue
+		}
+		result.matches++
+
+		start := max(i-contextLines, last+1)
+		if contextLines > 0 && last >= 0 && start > last+1 {
+			result.lines = append(result.lines, searchLine{text: "--"})
+		}
+		for j := start; j < i; j++ {
+			result.lines = append(result.lines, searchLine{text: formatSearchLine(name, j, '-', lines[j])})
+		}
+		result.lines = append(result.lines, searchLine{text: formatSearchLine(name, i, ':', line), match: true})
+		last = i
+
+		// Trailing context stops at the next match, which adds its own.
+		for j := i + 1; j <= i+contextLines && j < len(lines); j++ {
+			if re.MatchString(strings.TrimSuffix(lines[j], "\r")) {
+				break
+			}
+			result.lines = append(result.lines, searchLine{text: formatSearchLine(name, j, '-', lines[j])})
+			last = j
+		}
+	}
+}
+
+// limitMatches keeps the output lines up to the n-th match and its trailing
+// context.
+func limitMatches(lines []searchLine, n int) []searchLine {
+	for i, line := range lines {
+		if !line.match {
+			continue
+		}
+		if n--; n > 0 {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !lines[end].match && lines[end].text != "--" {
+			end++
+		}
+		return lines[:end]
+	}
+	return lines
+}
+
+func formatSearchLine(name string, index int, sep byte, text string) string {
+	return fmt.Sprintf("%s%c%d%c%s", name, sep, index+1, sep, truncateLine(text))
+}
+
+// truncateLine cuts a line longer than maxSearchLineLength at a rune boundary.
+func truncateLine(text string) string {
+	text = strings.TrimSuffix(text, "\r")
+	if len(text) > maxSearchLineLength {
+		cut := maxSearchLineLength
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "…"
+	}
+	return text
+}
+
+// isBinary reports whether data looks like a binary file: it has a NUL byte
+// in its first 8000 bytes, like git's heuristic.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
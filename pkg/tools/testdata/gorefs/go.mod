@@ -0,0 +1,3 @@
+module example.com/gorefs
+
+go 1.24
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"fmt"
+
+	"example.com/gorefs/shapes"
+)
+
+func main() {
+	sq := shapes.Square{Side: 4}
+	sq.Label.Text = "four"
+	fmt.Println(sq.Text, sq.Area(), shapes.Scale(sq, 2), shapes.Clamp(12))
+}
@@ -0,0 +1,14 @@
+package shapes_test
+
+import (
+	"testing"
+
+	"example.com/gorefs/shapes"
+)
+
+func TestLabel(t *testing.T) {
+	sq := shapes.Square{Label: shapes.Label{Text: "a"}, Side: 2}
+	if sq.Label.Text != "a" || shapes.Scale(sq, 2) != 8 {
+		t.Error("unexpected square")
+	}
+}
@@ -0,0 +1,37 @@
+// Package shapes is a fixture for the find_references and rename_symbol
+// tests.
+package shapes
+
+// Label names a shape.
+type Label struct {
+	Text string
+}
+
+// Square is a square with an embedded label.
+type Square struct {
+	Label
+	Side int
+}
+
+// Area returns the area of s.
+func (s Square) Area() int {
+	return s.Side * s.Side
+}
+
+// Scale returns the area of s scaled by factor.
+func Scale(s Square, factor int) int {
+	total := s.Area()
+	scaled := total * factor
+	return scaled
+}
+
+var limit = 10
+
+// Clamp limits n to the package limit.
+func Clamp(n int) int {
+	size := n
+	if size > limit {
+		size = limit
+	}
+	return size
+}
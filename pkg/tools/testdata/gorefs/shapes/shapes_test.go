@@ -0,0 +1,9 @@
+package shapes
+
+import "testing"
+
+func TestArea(t *testing.T) {
+	if got := (Square{Side: 3}).Area(); got != 9 {
+		t.Errorf("Area() = %d, want 9", got)
+	}
+}
@@ -2,137 +2,190 @@
 package tools
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"iter"
-	"maps"
-	"slices"
+	"strings"
+
+	"cooder-assist/pkg/lsp"
 
 	"google.golang.org/genai"
 )
 
-type Tools []*genai.Tool // Tools is a slice of genai.Tool.
+// Tool is a function the model can call. Implementations only need to be
+// registered with Tools.Register to be offered to the model.
+type Tool interface {
+	// Name is the function name the model calls the tool by.
+	Name() string
+	// Declaration describes the tool and its arguments to the model. Calls are
+	// validated against its parameter schema before Execute is called.
+	Declaration() *genai.FunctionDeclaration
+	// Execute runs the tool with the arguments sent by the model and returns
+	// the output passed back to it.
+	Execute(ctx context.Context, env *Env, args map[string]any) (string, error)
+}
+
+// Previewer is implemented by tools that change the workspace. Preview
+// describes the change a call would make so the user can approve it; a nil
+// request means the call needs no approval.
+type Previewer interface {
+	Preview(ctx context.Context, env *Env, args map[string]any) (*ApprovalRequest, error)
+}
+
+// Env is the state shared by every tool call.
+type Env struct {
+	// Workspace confines the file tools. A nil Workspace is rooted at the
+	// current directory.
+	Workspace *Workspace
+	// Commands controls what run_command may execute.
+	Commands CommandPolicy
+	// MaxReadBytes caps the output of read_file. Defaults to 100KiB.
+	MaxReadBytes int
+	// Checkpoints, when set, records the files every mutating tool call
+	// changes so the change can be undone.
+	Checkpoints *Checkpoints
+	// LSP, when set, is the language server the diagnostics, go_to_definition
+	// and hover tools ask. Files written by tools are synced to it.
+	LSP *lsp.Client
+	// AppendDiagnostics appends the language server's errors and warnings
+	// for the files an edit tool wrote to its output.
+	AppendDiagnostics bool
+}
 
-// New creates a new set of tools.
-func New() Tools {
-	genAITools := make([]*genai.Tool, 0)
-	genAITools = append(genAITools, readFileTool, listFilesTool, editFileTool, createFileTool, gitCommitTool, diffTool)
-	return genAITools
+// Func adapts a function taking typed arguments to the Tool interface. The
+// arguments sent by the model are decoded into A through their JSON form, so
+// A is usually a struct with json tags matching the declared parameters.
+type Func[A any] struct {
+	Decl *genai.FunctionDeclaration
+	Run  func(ctx context.Context, env *Env, args A) (string, error)
+	// PreviewRun, when set, makes the tool require approval before Run.
+	PreviewRun func(ctx context.Context, env *Env, args A) (*ApprovalRequest, error)
 }
 
-var (
-	// expectedArgs defines the expected arguments for each tool.
-	expectedArgs = map[string]iter.Seq[string]{
-		"read_file":   maps.Keys(readFileTool.FunctionDeclarations[0].Parameters.Properties),
-		"list_files":  maps.Keys(listFilesTool.FunctionDeclarations[0].Parameters.Properties),
-		"edit_file":   maps.Keys(editFileTool.FunctionDeclarations[0].Parameters.Properties),
-		"create_file": maps.Keys(createFileTool.FunctionDeclarations[0].Parameters.Properties),
-		"git_commit":  maps.Keys(gitCommitTool.FunctionDeclarations[0].Parameters.Properties),
-		"diff":        maps.Keys(diffTool.FunctionDeclarations[0].Parameters.Properties),
+func (f Func[A]) Name() string {
+	return f.Decl.Name
+}
+
+func (f Func[A]) Declaration() *genai.FunctionDeclaration {
+	return f.Decl
+}
+
+// Decode converts the raw call arguments into A.
+func (f Func[A]) Decode(args map[string]any) (A, error) {
+	var decoded A
+	data, err := json.Marshal(args)
+	if err != nil {
+		return decoded, fmt.Errorf("invalid arguments for tool '%s': %w", f.Decl.Name, err)
 	}
-)
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return decoded, fmt.Errorf("invalid arguments for tool '%s': %w", f.Decl.Name, err)
+	}
+	return decoded, nil
+}
 
-// ExecuteTool executes the tool specified in the given genai.FunctionCall.
-// It validates the arguments and calls the appropriate tool function.
-func (t Tools) ExecuteTool(call *genai.FunctionCall) *genai.FunctionResponse {
-	response := make(map[string]any)
+func (f Func[A]) Execute(ctx context.Context, env *Env, args map[string]any) (string, error) {
+	decoded, err := f.Decode(args)
+	if err != nil {
+		return "", err
+	}
+	return f.Run(ctx, env, decoded)
+}
 
-	name := call.Name
-	// Get the arguments passed to the tool
-	received := maps.Keys(call.Args)
-	// Check if the tool name is valid
-	if _, ok := expectedArgs[name]; !ok {
-		response["error"] = fmt.Errorf("tool named '%s' is unknown", name)
-	} else {
-		// Check if all required parameters are present
-		var missingRequired []string
-		var requiredParams []string
-
-		// Get required parameters based on tool name
-		switch name {
-		case "read_file", "list_files":
-			requiredParams = []string{"path"}
-		case "edit_file":
-			requiredParams = []string{"path", "old_string", "new_string"}
-		case "create_file":
-			requiredParams = []string{"path", "content"}
-		case "git_commit":
-			requiredParams = []string{"message"}
-		case "diff":
-			requiredParams = []string{"old_string", "new_string"}
-		}
+func (f Func[A]) Preview(ctx context.Context, env *Env, args map[string]any) (*ApprovalRequest, error) {
+	if f.PreviewRun == nil {
+		return nil, nil
+	}
+	decoded, err := f.Decode(args)
+	if err != nil {
+		return nil, err
+	}
+	return f.PreviewRun(ctx, env, decoded)
+}
 
-		// Check for missing required parameters
-		receivedSlice := slices.Collect(received)
-		for _, required := range requiredParams {
-			if !slices.Contains(receivedSlice, required) {
-				missingRequired = append(missingRequired, required)
-			}
-		}
+// builtins are the tools registered by New. Each tool file adds its own tool
+// from an init function.
+var builtins []Tool
+
+// Tools is the registry of tools offered to the model. It executes the calls
+// the model makes.
+type Tools struct {
+	Env
+	// Approver is asked before tools implementing Previewer run.
+	// A nil Approver approves every call.
+	Approver Approver
 
-		if len(missingRequired) > 0 {
-			response["error"] = fmt.Errorf("for tool named '%s' missing required arguments: %v", name, missingRequired)
-		} else if call.Name == "read_file" {
-			// Execute read file tool
-			content, err := ReadFile(call.Args["path"].(string))
-			if err == nil {
-				response["output"] = content
-			} else {
-				response["error"] = err.Error()
-			}
-		} else if call.Name == "list_files" {
-			// Execute list files tool
-			content, err := ListFiles(call.Args["path"].(string))
-			if err == nil {
-				response["output"] = content
-			} else {
-				response["error"] = err.Error()
-			}
-		} else if call.Name == "edit_file" {
-			// Execute edit file tool
-			err := EditFile(call.Args["path"].(string), call.Args["old_string"].(string), call.Args["new_string"].(string))
-			if err == nil {
-				response["output"] = "OK"
-			} else {
-				response["error"] = err.Error()
-			}
-		} else if call.Name == "create_file" {
-			// Execute create file tool
-			path := call.Args["path"].(string)
-			content := call.Args["content"].(string)
-
-			// Handle optional overwrite parameter
-			var overwrite *bool
-			if overwriteVal, exists := call.Args["overwrite"]; exists {
-				if boolVal, ok := overwriteVal.(bool); ok {
-					overwrite = &boolVal
-				}
-			}
-
-			err := CreateFileWithDefaults(path, content, overwrite)
-			if err == nil {
-				response["output"] = fmt.Sprintf("File '%s' created successfully", path)
-			} else {
-				response["error"] = err.Error()
-			}
-		} else if call.Name == "git_commit" {
-			message := call.Args["message"].(string)
-			content, err := GitCommit(message)
-			if err == nil {
-				response["output"] = content
-			} else {
-				response["error"] = err.Error()
-			}
-		} else if call.Name == "diff" {
-			// Execute diff tool
-			content, err := Diff(call.Args["old_string"].(string), call.Args["new_string"].(string))
-			if err == nil {
-				response["output"] = content
-			} else {
-				response["error"] = err.Error()
-			}
+	registry       map[string]Tool
+	order          []string
+	alwaysApproved map[string]bool
+}
 
+// New creates a registry holding the built-in tools.
+func New() *Tools {
+	t := &Tools{registry: make(map[string]Tool)}
+	for _, tool := range builtins {
+		if err := t.Register(tool); err != nil {
+			panic(err)
 		}
 	}
+	return t
+}
+
+// Register adds a tool to the registry. Tools must be registered before the
+// model provider is created, as providers read the declarations once.
+func (t *Tools) Register(tool Tool) error {
+	name := tool.Name()
+	if name == "" {
+		return errors.New("tool name is empty")
+	}
+	if decl := tool.Declaration(); decl == nil || decl.Name != name {
+		return fmt.Errorf("declaration of tool '%s' does not match its name", name)
+	}
+	if _, exists := t.registry[name]; exists {
+		return fmt.Errorf("tool named '%s' is already registered", name)
+	}
+	if t.registry == nil {
+		t.registry = make(map[string]Tool)
+	}
+	t.registry[name] = tool
+	t.order = append(t.order, name)
+	return nil
+}
+
+// Lookup returns the tool registered under name.
+func (t *Tools) Lookup(name string) (Tool, bool) {
+	tool, ok := t.registry[name]
+	return tool, ok
+}
+
+// Declarations returns the declarations of every registered tool, in
+// registration order.
+func (t *Tools) Declarations() []*genai.Tool {
+	decls := make([]*genai.FunctionDeclaration, 0, len(t.order))
+	for _, name := range t.order {
+		decls = append(decls, t.registry[name].Declaration())
+	}
+	return []*genai.Tool{{FunctionDeclarations: decls}}
+}
+
+// ExecuteTool executes the tool specified in the given genai.FunctionCall.
+// It validates the arguments against the declared schema, asks the Approver
+// about tools that change the workspace and runs the tool.
+func (t *Tools) ExecuteTool(ctx context.Context, call *genai.FunctionCall) *genai.FunctionResponse {
+	response := make(map[string]any)
+
+	tool, ok := t.registry[call.Name]
+	if !ok {
+		response["error"] = fmt.Sprintf("tool named '%s' is unknown", call.Name)
+	} else if violations := ValidateArgs(tool.Declaration().Parameters, call.Args); len(violations) > 0 {
+		response["error"] = fmt.Sprintf("invalid arguments for tool named '%s':\n- %s", call.Name, strings.Join(violations, "\n- "))
+	} else if rejected := t.approve(ctx, tool, call.Args); rejected != nil {
+		response = rejected
+	} else if output, err := tool.Execute(ctx, &t.Env, call.Args); err != nil {
+		response["error"] = err.Error()
+	} else {
+		response["output"] = output
+	}
 
 	return &genai.FunctionResponse{
 		ID:       call.ID,
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// ValidateArgs checks the arguments of a call against the parameter schema of
+// its declaration: value types, required properties, enums, numeric bounds and
+// unknown properties. It returns every violation found, in a stable order.
+func ValidateArgs(schema *genai.Schema, args map[string]any) []string {
+	if schema == nil {
+		if len(args) == 0 {
+			return nil
+		}
+		var violations []string
+		for _, name := range sortedKeys(args) {
+			violations = append(violations, fmt.Sprintf("unknown argument '%s'", name))
+		}
+		return violations
+	}
+
+	var violations []string
+	validateObject("", schema, args, &violations)
+	return violations
+}
+
+func validateValue(path string, schema *genai.Schema, value any, violations *[]string) {
+	if value == nil {
+		if schema.Nullable == nil || !*schema.Nullable {
+			*violations = append(*violations, fmt.Sprintf("%s: must not be null", path))
+		}
+		return
+	}
+
+	switch schema.Type {
+	case genai.TypeString:
+		str, ok := value.(string)
+		if !ok {
+			*violations = append(*violations, typeViolation(path, "string", value))
+			return
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, str) {
+			*violations = append(*violations, fmt.Sprintf("%s: '%s' is not one of %s", path, str, strings.Join(schema.Enum, ", ")))
+		}
+	case genai.TypeInteger:
+		num, ok := number(value)
+		if !ok || num != math.Trunc(num) {
+			*violations = append(*violations, typeViolation(path, "integer", value))
+			return
+		}
+		validateBounds(path, schema, num, violations)
+	case genai.TypeNumber:
+		num, ok := number(value)
+		if !ok {
+			*violations = append(*violations, typeViolation(path, "number", value))
+			return
+		}
+		validateBounds(path, schema, num, violations)
+	case genai.TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			*violations = append(*violations, typeViolation(path, "boolean", value))
+		}
+	case genai.TypeArray:
+		items, ok := value.([]any)
+		if !ok {
+			*violations = append(*violations, typeViolation(path, "array", value))
+			return
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), schema.Items, item, violations)
+			}
+		}
+	case genai.TypeObject:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			*violations = append(*violations, typeViolation(path, "object", value))
+			return
+		}
+		validateObject(path+".", schema, obj, violations)
+	}
+}
+
+// validateObject checks the properties of obj. prefix is prepended to the
+// property names in the reported violations.
+func validateObject(prefix string, schema *genai.Schema, obj map[string]any, violations *[]string) {
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			*violations = append(*violations, fmt.Sprintf("missing required argument '%s%s'", prefix, name))
+		}
+	}
+	for _, name := range sortedKeys(obj) {
+		prop, ok := schema.Properties[name]
+		if !ok {
+			*violations = append(*violations, fmt.Sprintf("unknown argument '%s%s'", prefix, name))
+			continue
+		}
+		validateValue(prefix+name, prop, obj[name], violations)
+	}
+}
+
+func validateBounds(path string, schema *genai.Schema, num float64, violations *[]string) {
+	if schema.Minimum != nil && num < *schema.Minimum {
+		*violations = append(*violations, fmt.Sprintf("%s: %v is less than the minimum %v", path, num, *schema.Minimum))
+	}
+	if schema.Maximum != nil && num > *schema.Maximum {
+		*violations = append(*violations, fmt.Sprintf("%s: %v is greater than the maximum %v", path, num, *schema.Maximum))
+	}
+}
+
+// number converts the numeric types produced by JSON decoding or by callers
+// building arguments by hand.
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func typeViolation(path, expected string, value any) string {
+	return fmt.Sprintf("%s: expected %s, got %s", path, expected, jsonType(value))
+}
+
+// jsonType names the JSON type of a decoded value.
+func jsonType(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	if _, ok := number(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
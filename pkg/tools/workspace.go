@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutsideWorkspace is returned when a tool is given a path that escapes the
+// workspace root.
+var ErrOutsideWorkspace = errors.New("path is outside the workspace")
+
+// Workspace confines the file tools to a root directory. Paths are resolved
+// against Root and rejected if they escape it once symlinks are followed.
+// ReadOnlyRoots are additional directories the read-only tools may access.
+type Workspace struct {
+	Root          string
+	ReadOnlyRoots []string
+}
+
+// NewWorkspace returns a workspace rooted at root, which defaults to the
+// current directory.
+func NewWorkspace(root string, readOnlyRoots []string) (*Workspace, error) {
+	if root == "" {
+		root = "."
+	}
+	resolved, err := realDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace root: %w", err)
+	}
+
+	ws := &Workspace{Root: resolved}
+	for _, dir := range readOnlyRoots {
+		resolved, err := realDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid read-only root: %w", err)
+		}
+		ws.ReadOnlyRoots = append(ws.ReadOnlyRoots, resolved)
+	}
+	return ws, nil
+}
+
+// Resolve returns the absolute, symlink-free location of path. Relative paths
+// are taken from the workspace root. Paths inside ReadOnlyRoots are only
+// accepted when write is false. A nil Workspace is rooted at the current
+// directory.
+func (w *Workspace) Resolve(path string, write bool) (string, error) {
+	if w == nil {
+		ws, err := NewWorkspace(".", nil)
+		if err != nil {
+			return "", err
+		}
+		w = ws
+	}
+
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(w.Root, target)
+	}
+	resolved, err := evalExisting(filepath.Clean(target))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path '%s': %w", path, err)
+	}
+
+	if within(w.Root, resolved) {
+		return resolved, nil
+	}
+	if !write {
+		for _, root := range w.ReadOnlyRoots {
+			if within(root, resolved) {
+				return resolved, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: '%s' (workspace root is %s)", ErrOutsideWorkspace, path, w.Root)
+}
+
+// Dir returns the workspace root, or the current directory for a nil Workspace.
+func (w *Workspace) Dir() string {
+	if w == nil {
+		return "."
+	}
+	return w.Root
+}
+
+// maxSymlinkHops bounds the dangling symlinks evalExisting follows, so a
+// link cycle cannot loop forever.
+const maxSymlinkHops = 255
+
+// evalExisting resolves symlinks in the longest existing prefix of path and
+// appends the components that do not exist yet, so files about to be created
+// are checked against the directory they will land in. A dangling symlink is
+// followed to its target, since writing through it creates the target.
+func evalExisting(path string) (string, error) {
+	var missing []string
+	current := path
+	hops := 0
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if info, err := os.Lstat(current); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if hops++; hops > maxSymlinkHops {
+				return "", fmt.Errorf("too many levels of symbolic links in '%s'", path)
+			}
+			target, err := os.Readlink(current)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(current), target)
+			}
+			current = filepath.Clean(target)
+			continue
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return path, nil
+		}
+		missing = append([]string{filepath.Base(current)}, missing...)
+		current = parent
+	}
+}
+
+func realDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	return resolved, nil
+}
+
+// within reports whether path is root or inside it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
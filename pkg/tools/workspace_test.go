@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(os.MkdirAll(filepath.Join(root, "sub"), 0o755))
+	must(os.WriteFile(filepath.Join(root, "sub", "a.go"), nil, 0o644))
+	must(os.Symlink(outside, filepath.Join(root, "out")))
+	must(os.Symlink(filepath.Join(outside, "new.go"), filepath.Join(root, "dangling.go")))
+	must(os.Symlink(filepath.Join(outside, "gone"), filepath.Join(root, "danglingdir")))
+	must(os.Symlink("sub/b.go", filepath.Join(root, "inside.go")))
+	must(os.Symlink("loop2", filepath.Join(root, "loop1")))
+	must(os.Symlink("loop1", filepath.Join(root, "loop2")))
+
+	ws, err := NewWorkspace(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		outside bool
+		wantErr bool
+	}{
+		{name: "existing file", path: "sub/a.go", want: filepath.Join(ws.Root, "sub", "a.go")},
+		{name: "missing file", path: "sub/new/b.go", want: filepath.Join(ws.Root, "sub", "new", "b.go")},
+		{name: "dot dot", path: "../x.go", outside: true},
+		{name: "symlinked directory outside", path: "out/x.go", outside: true},
+		{name: "dangling symlink outside", path: "dangling.go", outside: true},
+		{name: "below dangling directory symlink", path: "danglingdir/x.go", outside: true},
+		{name: "dangling symlink inside", path: "inside.go", want: filepath.Join(ws.Root, "sub", "b.go")},
+		{name: "symlink loop", path: "loop1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ws.Resolve(tt.path, true)
+			switch {
+			case tt.outside:
+				if !errors.Is(err, ErrOutsideWorkspace) {
+					t.Fatalf("Resolve(%q) = %q, %v, want ErrOutsideWorkspace", tt.path, got, err)
+				}
+			case tt.wantErr:
+				if err == nil {
+					t.Fatalf("Resolve(%q) = %q, want an error", tt.path, got)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case got != tt.want:
+				t.Errorf("Resolve(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateFileDanglingSymlink(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "escaped.txt")
+	link := filepath.Join(root, "link.txt")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := NewWorkspace(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = createFileTool.Run(context.Background(), &Env{Workspace: ws}, createFileArgs{Path: "link.txt", Content: "x"})
+	if !errors.Is(err, ErrOutsideWorkspace) {
+		t.Fatalf("create_file through a dangling symlink: %v, want ErrOutsideWorkspace", err)
+	}
+
+	// Even given the link itself, CreateFile replaces it instead of writing
+	// through it.
+	if err := CreateFile(link, "x", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(outside); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the symlink target outside the root was written: %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("%s is not a regular file after CreateFile: %v", link, err)
+	}
+}
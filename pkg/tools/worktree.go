@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WorktreeBranchPrefix names the branches of session worktrees.
+const WorktreeBranchPrefix = "cooder/"
+
+// Worktree is a git worktree the agent works in so that its changes stay on
+// their own branch until the user merges them.
+type Worktree struct {
+	// Repo is the top level of the main working tree.
+	Repo string
+	// Dir is the directory of the worktree.
+	Dir string
+	// Branch is checked out in the worktree.
+	Branch string
+	// Base is the branch checked out in Repo when the worktree was created,
+	// which Merge merges into.
+	Base string
+}
+
+// OpenWorktree returns the worktree at dir for the repository containing
+// repoDir, creating it with a new branch off the current HEAD when it does
+// not exist yet. Uncommitted changes in the main working tree are not carried
+// over.
+func OpenWorktree(ctx context.Context, repoDir, dir, branch string) (*Worktree, error) {
+	top, _, err := Git(ctx, repoDir, 0, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("worktree mode needs a git repository: %w", err)
+	}
+	base, _, err := Git(ctx, repoDir, 0, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	wt := &Worktree{Repo: strings.TrimSpace(top), Dir: dir, Branch: branch, Base: strings.TrimSpace(base)}
+
+	if _, err := os.Stat(dir); err == nil {
+		current, _, err := Git(ctx, dir, 0, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a usable worktree: %w", dir, err)
+		}
+		wt.Branch = strings.TrimSpace(current)
+		return wt, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to check worktree %s: %w", dir, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory for worktree %s: %w", dir, err)
+	}
+	if _, _, err := Git(ctx, wt.Repo, 0, "worktree", "add", "-b", branch, "--", dir, "HEAD"); err != nil {
+		return nil, err
+	}
+	return wt, nil
+}
+
+// Dirty reports whether the worktree has uncommitted changes.
+func (wt *Worktree) Dirty(ctx context.Context) (bool, error) {
+	out, _, err := Git(ctx, wt.Dir, 0, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return out != "", nil
+}
+
+// Merge merges the worktree branch into the branch checked out in the main
+// working tree and removes the worktree. On a conflict the merge is left in
+// progress in the main working tree for the user to resolve and the worktree
+// is kept.
+func (wt *Worktree) Merge(ctx context.Context) (string, error) {
+	dirty, err := wt.Dirty(ctx)
+	if err != nil {
+		return "", err
+	}
+	if dirty {
+		return "", fmt.Errorf("the worktree %s has uncommitted changes; commit or discard them first", wt.Dir)
+	}
+	// The agent may have switched branches inside the worktree.
+	branch, _, err := Git(ctx, wt.Dir, 0, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	wt.Branch = strings.TrimSpace(branch)
+	current, _, err := Git(ctx, wt.Repo, 0, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if current = strings.TrimSpace(current); current != wt.Base {
+		return "", fmt.Errorf("%s now has '%s' checked out instead of '%s'; merge '%s' yourself", wt.Repo, current, wt.Base, wt.Branch)
+	}
+	out, _, err := Git(ctx, wt.Repo, 0, "merge", "--no-edit", wt.Branch)
+	if err != nil {
+		return "", err
+	}
+	if err := wt.remove(ctx, false); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// Discard removes the worktree and deletes its branch, dropping every change
+// made in it.
+func (wt *Worktree) Discard(ctx context.Context) error {
+	return wt.remove(ctx, true)
+}
+
+// remove deletes the worktree and its branch. Without force, the branch is
+// only deleted when it has been merged.
+func (wt *Worktree) remove(ctx context.Context, force bool) error {
+	args := []string{"worktree", "remove"}
+	deleteFlag := "-d"
+	if force {
+		args = append(args, "--force")
+		deleteFlag = "-D"
+	}
+	if _, _, err := Git(ctx, wt.Repo, 0, append(args, "--", wt.Dir)...); err != nil {
+		return err
+	}
+	_, _, err := Git(ctx, wt.Repo, 0, "branch", deleteFlag, "--", wt.Branch)
+	return err
+}